@@ -0,0 +1,258 @@
+package http
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// BindError returned when request header, cookie or path parameter can't be bound to request field
+type BindError struct {
+	Err error
+	// Source is header, cookie or path
+	Source string
+	Name   string
+	Field  string
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("invalid %s %s for field %s: %v", e.Source, e.Name, e.Field, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+var errBindRequired = fmt.Errorf("required")
+
+// bindSources lists tags and HandlerBinding sources of request fields
+var bindSources = []string{"header", "cookie", "path"}
+
+// bindField describes request field filled from header, cookie or path parameter
+type bindField struct {
+	source string
+	name   string
+	// field is struct field name or proto field path
+	field    string
+	index    []int
+	required bool
+}
+
+// newBindField returns field bound to source by tag value like X-Tenant,required
+func newBindField(source, tag, field string, index []int) bindField {
+	name, opt, _ := strings.Cut(tag, ",")
+	return bindField{source: source, name: name, field: field, index: index, required: opt == "required"}
+}
+
+// values returns field values from request, error if required value missing
+func (f bindField) values(r *http.Request, params map[string]string) ([]string, error) {
+	var vals []string
+	switch f.source {
+	case "header":
+		vals = r.Header.Values(f.name)
+	case "cookie":
+		if c, err := r.Cookie(f.name); err == nil {
+			vals = []string{c.Value}
+		}
+	case "path":
+		if v, ok := params[f.name]; ok {
+			vals = []string{v}
+		}
+	}
+
+	if len(vals) == 0 && f.required {
+		return nil, &BindError{Source: f.source, Name: f.name, Field: f.field, Err: errBindRequired}
+	}
+
+	return vals, nil
+}
+
+// bindings returns proto request fields bindings passed via HandlerBinding
+func (h *httpHandler) bindings() []bindField {
+	v, _ := h.opts.Context.Value(handlerBindingsKey{}).([]bindField)
+	return v
+}
+
+// bindFields caches fields with header, cookie and path tags by request type
+var bindFields sync.Map
+
+// fieldsToBind returns fields of struct type with tags like header:"X-Tenant", cookie:"session,required"
+// or path:"id", fields of embedded structs included
+func fieldsToBind(t reflect.Type) []bindField {
+	if v, ok := bindFields.Load(t); ok {
+		return v.([]bindField)
+	}
+
+	var fields []bindField
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fidx := append(index[:len(index):len(index)], i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				walk(f.Type, fidx)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			for _, source := range bindSources {
+				tag, ok := f.Tag.Lookup(source)
+				if !ok || tag == "" || tag == "-" {
+					continue
+				}
+				fields = append(fields, newBindField(source, tag, f.Name, fidx))
+			}
+		}
+	}
+	walk(t, nil)
+
+	bindFields.Store(t, fields)
+	return fields
+}
+
+// bindRequest fills request struct fields tagged with header, cookie or path from http request and
+// path parameters, proto message fields filled by bindings
+func bindRequest(r *http.Request, req interface{}, params map[string]string, bindings []bindField) error {
+	if pb, ok := req.(proto.Message); ok {
+		m := pb.ProtoReflect()
+		for _, f := range bindings {
+			vals, err := f.values(r, params)
+			if err != nil {
+				return err
+			}
+			if len(vals) == 0 {
+				continue
+			}
+			if err = setQueryField(m, f.field, vals); err != nil {
+				if err == errUnknownQueryParam {
+					err = fmt.Errorf("unknown field")
+				}
+				return &BindError{Source: f.source, Name: f.name, Field: f.field, Err: err}
+			}
+		}
+	}
+
+	rv := reflect.ValueOf(req)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for _, f := range fieldsToBind(rv.Type()) {
+		vals, err := f.values(r, params)
+		if err != nil {
+			return err
+		}
+		if len(vals) == 0 {
+			continue
+		}
+
+		if err := setFieldValue(rv.FieldByIndex(f.index), vals); err != nil {
+			return &BindError{Source: f.source, Name: f.name, Field: f.field, Err: err}
+		}
+	}
+
+	return nil
+}
+
+var (
+	typeOfDuration        = reflect.TypeOf(time.Duration(0))
+	typeOfTime            = reflect.TypeOf(time.Time{})
+	typeOfTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setFieldValue converts values to field type, slices filled by all values, other types by first one
+func setFieldValue(fv reflect.Value, vals []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+		sv := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(sv.Index(i), val); err != nil {
+				return err
+			}
+		}
+		fv.Set(sv)
+		return nil
+	}
+	return setValue(fv, vals[0])
+}
+
+func setValue(fv reflect.Value, val string) error {
+	if fv.Kind() == reflect.Ptr {
+		nv := reflect.New(fv.Type().Elem())
+		if err := setValue(nv.Elem(), val); err != nil {
+			return err
+		}
+		fv.Set(nv)
+		return nil
+	}
+
+	if fv.CanAddr() && fv.Addr().Type().Implements(typeOfTextUnmarshaler) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+
+	switch fv.Type() {
+	case typeOfDuration:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case typeOfTime:
+		ts, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(ts))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		// []byte
+		fv.SetBytes([]byte(val))
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+type bindTestEmbedded struct {
+	Session string `cookie:"session"`
+}
+
+type BindTestRequest struct {
+	bindTestEmbedded
+	Deadline time.Time     `header:"X-Deadline"`
+	Limit    *int32        `header:"X-Limit"`
+	Tenant   string        `header:"X-Tenant,required"`
+	Tags     []string      `header:"X-Tag"`
+	Timeout  time.Duration `header:"X-Timeout"`
+	Debug    bool          `header:"X-Debug"`
+	Name     string        `json:"name"`
+	Resource string        `path:"name"`
+}
+
+func TestBindRequest(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, err)
+	r.Header.Set("X-Tenant", "acme")
+	r.Header.Set("X-Limit", "10")
+	r.Header.Add("X-Tag", "a")
+	r.Header.Add("X-Tag", "b")
+	r.Header.Set("X-Timeout", "5s")
+	r.Header.Set("X-Debug", "true")
+	r.Header.Set("X-Deadline", "2024-01-02T03:04:05Z")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	req := &BindTestRequest{}
+	require.NoError(t, bindRequest(r, req, map[string]string{"name": "abc"}, nil))
+	require.Equal(t, "acme", req.Tenant)
+	require.Equal(t, int32(10), *req.Limit)
+	require.Equal(t, []string{"a", "b"}, req.Tags)
+	require.Equal(t, 5*time.Second, req.Timeout)
+	require.True(t, req.Debug)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), req.Deadline)
+	require.Equal(t, "abc", req.Session)
+	require.Equal(t, "abc", req.Resource)
+
+	r.Header.Set("X-Limit", "many")
+	var berr *BindError
+	require.ErrorAs(t, bindRequest(r, &BindTestRequest{}, nil, nil), &berr)
+	require.Equal(t, "header", berr.Source)
+	require.Equal(t, "Limit", berr.Field)
+
+	r.Header.Del("X-Limit")
+	r.Header.Del("X-Tenant")
+	err = bindRequest(r, &BindTestRequest{}, nil, nil)
+	require.ErrorAs(t, err, &berr)
+	require.True(t, errors.Is(err, errBindRequired))
+
+	// types without tags ignored
+	require.NoError(t, bindRequest(r, &EchoRequest{}, nil, nil))
+}
+
+type BindTestHandler struct{}
+
+func (*BindTestHandler) Get(ctx context.Context, req *BindTestRequest, rsp *EchoResponse) error {
+	rsp.Name = req.Name + " " + req.Tenant + " " + req.Session
+	return nil
+}
+
+func TestServerBindRequest(t *testing.T) {
+	srv := newTestServer(t)
+	require.NoError(t, srv.Handle(srv.NewHandler(&BindTestHandler{}, HandlerEndpoints([]EndpointMetadata{
+		{Name: "BindTest.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+	}))))
+	c := startTestServer(t, srv)
+
+	get := func(tenant string) (int, string) {
+		req := c.request(http.MethodGet, "/v1/abc", nil)
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+		rsp, body := c.do(req)
+		return rsp.StatusCode, body
+	}
+
+	code, body := get("acme")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"name":"abc acme s1"}`, body)
+
+	code, body = get("")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.True(t, strings.Contains(body, "X-Tenant"), body)
+}
+
+type BindProtoTestHandler struct{}
+
+func (*BindProtoTestHandler) Get(ctx context.Context, req *typepb.Field, rsp *EchoResponse) error {
+	rsp.Name = fmt.Sprintf("%s %d %s", req.Name, req.Number, req.TypeUrl)
+	return nil
+}
+
+func TestServerBindProto(t *testing.T) {
+	srv := newTestServer(t)
+	require.NoError(t, srv.Handle(srv.NewHandler(&BindProtoTestHandler{},
+		HandlerBinding("name", "header", "X-Field-Name,required"),
+		HandlerBinding("number", "path", "num"),
+		HandlerBinding("typeUrl", "cookie", "type"),
+		HandlerEndpoints([]EndpointMetadata{
+			{Name: "BindProtoTest.Get", Method: http.MethodGet, Path: "/v1/fields/{num}"},
+		}),
+	)))
+	c := startTestServer(t, srv)
+
+	get := func(path, name string) (int, string) {
+		req := c.request(http.MethodGet, path, nil)
+		if name != "" {
+			req.Header.Set("X-Field-Name", name)
+		}
+		req.AddCookie(&http.Cookie{Name: "type", Value: "t1"})
+		rsp, body := c.do(req)
+		return rsp.StatusCode, body
+	}
+
+	code, body := get("/v1/fields/7", "id")
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"name":"id 7 t1"}`, body)
+
+	code, body = get("/v1/fields/7", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.True(t, strings.Contains(body, "X-Field-Name"), body)
+
+	code, body = get("/v1/fields/seven", "id")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.True(t, strings.Contains(body, "invalid path num"), body)
+}
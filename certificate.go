@@ -0,0 +1,264 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.unistack.org/micro/v4/logger"
+	"go.unistack.org/micro/v4/meter"
+)
+
+var (
+	// DefaultCertificateReloadInterval specifies how often certificate files checked for changes
+	DefaultCertificateReloadInterval = 10 * time.Second
+
+	// ServerTLSCertificateReloadTotal specifies meter metric name for certificate reloads
+	ServerTLSCertificateReloadTotal = "micro_server_tls_certificate_reload_total"
+)
+
+// CertificateFile holds paths to pem encoded certificate chain and private key
+type CertificateFile struct {
+	CertFile string
+	KeyFile  string
+}
+
+// CertificateConfig holds settings of reloadable tls certificates
+type CertificateConfig struct {
+	// Files contains certificate and key pairs
+	Files []CertificateFile
+	// Dirs contains directories with name.crt and name.key or name.pem and name-key.pem pairs,
+	// kubernetes tls secrets with tls.crt and tls.key supported too
+	Dirs []string
+	// Interval specifies how often files checked for changes, DefaultCertificateReloadInterval if zero
+	Interval time.Duration
+}
+
+// certificateSet holds loaded certificates indexed by names for SNI
+type certificateSet struct {
+	names     map[string]*tls.Certificate
+	wildcards map[string]*tls.Certificate
+	def       *tls.Certificate
+	hash      []byte
+	certs     []*tls.Certificate
+}
+
+// certificateReloader loads certificates from files and atomically swaps them on changes
+type certificateReloader struct {
+	logger logger.Logger
+	meter  meter.Meter
+	set    atomic.Pointer[certificateSet]
+	cfg    CertificateConfig
+}
+
+func newCertificateReloader(cfg CertificateConfig, l logger.Logger, m meter.Meter) (*certificateReloader, error) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultCertificateReloadInterval
+	}
+
+	r := &certificateReloader{cfg: cfg, logger: l, meter: m}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// files returns all configured certificate pairs, directory pairs sorted by name
+func (r *certificateReloader) files() ([]CertificateFile, error) {
+	files := append([]CertificateFile{}, r.cfg.Files...)
+
+	for _, dir := range r.cfg.Dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		var pairs []CertificateFile
+		for _, entry := range entries {
+			name := entry.Name()
+			// skip hidden files and kubernetes atomic writer dirs like ..data
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			var keyName string
+			switch {
+			case strings.HasSuffix(name, ".crt"):
+				keyName = strings.TrimSuffix(name, ".crt") + ".key"
+			case strings.HasSuffix(name, ".pem") && !strings.HasSuffix(name, "-key.pem"):
+				keyName = strings.TrimSuffix(name, ".pem") + "-key.pem"
+			default:
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, keyName)); err != nil {
+				continue
+			}
+			pairs = append(pairs, CertificateFile{CertFile: filepath.Join(dir, name), KeyFile: filepath.Join(dir, keyName)})
+		}
+
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].CertFile < pairs[j].CertFile })
+		files = append(files, pairs...)
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no tls certificates found")
+	}
+
+	return files, nil
+}
+
+// load reads and validates all certificates, on any error nothing returned
+func (r *certificateReloader) load() (*certificateSet, error) {
+	files, err := r.files()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &certificateSet{
+		names:     make(map[string]*tls.Certificate),
+		wildcards: make(map[string]*tls.Certificate),
+	}
+
+	hash := sha256.New()
+	for _, f := range files {
+		certPEM, err := os.ReadFile(f.CertFile)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err := os.ReadFile(f.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = hash.Write(certPEM)
+		_, _ = hash.Write(keyPEM)
+
+		crt, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate %s: %w", f.CertFile, err)
+		}
+		if crt.Leaf == nil {
+			if crt.Leaf, err = x509.ParseCertificate(crt.Certificate[0]); err != nil {
+				return nil, fmt.Errorf("invalid certificate %s: %w", f.CertFile, err)
+			}
+		}
+		if time.Now().After(crt.Leaf.NotAfter) {
+			return nil, fmt.Errorf("invalid certificate %s: expired at %s", f.CertFile, crt.Leaf.NotAfter)
+		}
+
+		set.certs = append(set.certs, &crt)
+
+		names := crt.Leaf.DNSNames
+		if len(names) == 0 && crt.Leaf.Subject.CommonName != "" {
+			names = []string{crt.Leaf.Subject.CommonName}
+		}
+		for _, name := range names {
+			name = strings.ToLower(name)
+			if strings.HasPrefix(name, "*.") {
+				if _, ok := set.wildcards[name[2:]]; !ok {
+					set.wildcards[name[2:]] = &crt
+				}
+			} else if _, ok := set.names[name]; !ok {
+				set.names[name] = &crt
+			}
+		}
+		for _, ip := range crt.Leaf.IPAddresses {
+			if _, ok := set.names[ip.String()]; !ok {
+				set.names[ip.String()] = &crt
+			}
+		}
+	}
+
+	set.def = set.certs[0]
+	set.hash = hash.Sum(nil)
+
+	return set, nil
+}
+
+// reload loads certificates and swaps them if changed, old certificates kept on error
+func (r *certificateReloader) reload() (bool, error) {
+	set, err := r.load()
+	if err != nil {
+		r.meter.Counter(ServerTLSCertificateReloadTotal, "server", "http", "status", "failure").Inc()
+		return false, err
+	}
+
+	if old := r.set.Load(); old != nil && bytes.Equal(old.hash, set.hash) {
+		return false, nil
+	}
+
+	r.set.Store(set)
+	r.meter.Counter(ServerTLSCertificateReloadTotal, "server", "http", "status", "success").Inc()
+
+	return true, nil
+}
+
+// watch checks certificate files for changes till context done
+func (r *certificateReloader) watch(ctx context.Context) {
+	t := time.NewTicker(r.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			changed, err := r.reload()
+			if err != nil {
+				if r.logger.V(logger.ErrorLevel) {
+					r.logger.Error(ctx, "tls certificates reload error, keep old certificates", err)
+				}
+				continue
+			}
+			if changed && r.logger.V(logger.InfoLevel) {
+				r.logger.Info(ctx, fmt.Sprintf("tls certificates reloaded: %d", len(r.set.Load().certs)))
+			}
+		}
+	}
+}
+
+// GetCertificate selects certificate by SNI, exact names preferred over wildcards
+func (r *certificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	set := r.set.Load()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name == "" && hello.Conn != nil {
+		// clients don't send ip address in SNI, so select by local address
+		if host, _, err := net.SplitHostPort(hello.Conn.LocalAddr().String()); err == nil {
+			name = host
+		}
+	}
+
+	if crt, ok := set.names[name]; ok {
+		return crt, nil
+	}
+
+	if idx := strings.IndexByte(name, '.'); idx > 0 {
+		if crt, ok := set.wildcards[name[idx+1:]]; ok {
+			return crt, nil
+		}
+	}
+
+	return set.def, nil
+}
+
+// tlsConfig returns copy of tls config that uses reloadable certificates
+func (r *certificateReloader) tlsConfig(tc *tls.Config) *tls.Config {
+	if tc == nil {
+		tc = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		tc = tc.Clone()
+	}
+	tc.Certificates = nil
+	tc.GetCertificate = r.GetCertificate
+	return tc
+}
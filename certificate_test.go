@@ -0,0 +1,82 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/logger"
+	"go.unistack.org/micro/v4/meter"
+	"go.unistack.org/micro/v4/server"
+)
+
+// writeTestCertificate writes certificate and key in pem format
+func writeTestCertificate(t *testing.T, certFile, keyFile string, crt tls.Certificate) {
+	t.Helper()
+
+	key, err := x509.MarshalPKCS8PrivateKey(crt.PrivateKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Certificate[0]}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+}
+
+func TestServerTLSCertificates(t *testing.T) {
+	dir := t.TempDir()
+
+	crtA, leafA := newTestCertificate(t, "a.example.com")
+	crtB, leafB := newTestCertificate(t, "*.b.example.com")
+	writeTestCertificate(t, filepath.Join(dir, "a.crt"), filepath.Join(dir, "a.key"), crtA)
+	writeTestCertificate(t, filepath.Join(dir, "b.pem"), filepath.Join(dir, "b-key.pem"), crtB)
+
+	srv := NewServer(
+		server.Address("127.0.0.1:0"),
+		TLSCertificates(CertificateConfig{Dirs: []string{dir}, Interval: 20 * time.Millisecond}),
+	)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+	defer func() {
+		require.NoError(t, srv.Stop())
+	}()
+
+	peer := func(name string) *x509.Certificate {
+		conn, err := tls.Dial("tcp", srv.Options().Address, &tls.Config{ServerName: name, InsecureSkipVerify: true})
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0]
+	}
+
+	require.Equal(t, leafA.SerialNumber, peer("a.example.com").SerialNumber)
+	require.Equal(t, leafB.SerialNumber, peer("x.b.example.com").SerialNumber)
+	// unknown name served with first certificate
+	require.Equal(t, leafA.SerialNumber, peer("unknown.example.com").SerialNumber)
+
+	// invalid file rejected, old certificate kept
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.crt"), []byte("invalid"), 0o600))
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, leafA.SerialNumber, peer("a.example.com").SerialNumber)
+
+	// rotated certificate used without restart
+	crtA2, leafA2 := newTestCertificate(t, "a.example.com")
+	writeTestCertificate(t, filepath.Join(dir, "a.crt"), filepath.Join(dir, "a.key"), crtA2)
+	require.Eventually(t, func() bool {
+		return peer("a.example.com").SerialNumber.Cmp(leafA2.SerialNumber) == 0
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestCertificateReloaderInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	_, err := newCertificateReloader(CertificateConfig{Dirs: []string{dir}}, logger.DefaultLogger, meter.DefaultMeter)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, []byte("invalid"), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, []byte("invalid"), 0o600))
+	_, err = newCertificateReloader(CertificateConfig{Files: []CertificateFile{{CertFile: certFile, KeyFile: keyFile}}}, logger.DefaultLogger, meter.DefaultMeter)
+	require.Error(t, err)
+}
@@ -0,0 +1,464 @@
+package http
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	fieldsQueryParam = "fields"
+	fieldsHeader     = "X-Fields"
+)
+
+// fieldMask is tree of selected reply fields, nil subtree selects whole field
+type fieldMask map[string]fieldMask
+
+// parseFieldMask parses comma separated dotted paths like name,items.id
+func parseFieldMask(s string) (fieldMask, error) {
+	mask := make(fieldMask)
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		node := mask
+		segs := strings.Split(p, ".")
+		for idx, seg := range segs {
+			if seg == "" {
+				return nil, fmt.Errorf("invalid field path %q", p)
+			}
+			if idx == len(segs)-1 {
+				node[seg] = nil
+				break
+			}
+			sub, ok := node[seg]
+			if ok && sub == nil {
+				// whole field already selected
+				break
+			}
+			if !ok {
+				sub = make(fieldMask)
+				node[seg] = sub
+			}
+			node = sub
+		}
+	}
+	if len(mask) == 0 {
+		return nil, nil
+	}
+	return mask, nil
+}
+
+// requestFieldMask returns mask from fields query parameter or X-Fields header validated against reply type,
+// nil if request not selects fields
+func requestFieldMask(r *http.Request, t reflect.Type) (fieldMask, error) {
+	s := r.URL.Query().Get(fieldsQueryParam)
+	if s == "" {
+		s = r.Header.Get(fieldsHeader)
+	}
+	if s == "" {
+		return nil, nil
+	}
+
+	mask, err := parseFieldMask(s)
+	if err == nil && mask != nil {
+		err = mask.validate(t, "")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid fields: %w", err)
+	}
+
+	return mask, nil
+}
+
+func (mask fieldMask) names() []string {
+	names := make([]string, 0, len(mask))
+	for name := range mask {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// validate checks that mask paths exist in type, fields matched by json names like encoding/json
+func (mask fieldMask) validate(t reflect.Type, path string) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(typeOfProtoMessage) {
+		return mask.validateProto(reflect.New(t).Interface().(proto.Message).ProtoReflect().Descriptor(), path)
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return mask.validate(t.Elem(), path)
+	}
+
+	for _, name := range mask.names() {
+		fpath := joinFieldPath(path, name)
+		if t.Kind() != reflect.Struct {
+			return fmt.Errorf("%w %q", errUnknownField, fpath)
+		}
+		_, f, ok := lookupJSONField(t, name)
+		if !ok {
+			return fmt.Errorf("%w %q", errUnknownField, fpath)
+		}
+		if sub := mask[name]; sub != nil {
+			if err := sub.validate(f.typ, fpath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateProto checks that mask paths exist in message, fields matched by proto or json names
+func (mask fieldMask) validateProto(md protoreflect.MessageDescriptor, path string) error {
+	for _, name := range mask.names() {
+		fpath := joinFieldPath(path, name)
+		var fd protoreflect.FieldDescriptor
+		if md != nil && !isWellKnownJSON(md) {
+			fd = queryField(md, name)
+		}
+		if fd == nil {
+			return fmt.Errorf("%w %q", errUnknownField, fpath)
+		}
+		sub := mask[name]
+		if sub == nil {
+			continue
+		}
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+		if err := sub.validateProto(fd.Message(), fpath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// structFields returns masks of selected struct fields by json names
+func (mask fieldMask) structFields(t reflect.Type) map[string]fieldMask {
+	selected := make(map[string]fieldMask, len(mask))
+	for name, sub := range mask {
+		fn, _, ok := lookupJSONField(t, name)
+		if !ok {
+			continue
+		}
+		if _, dup := selected[fn]; dup {
+			// field selected by several names, keep it whole
+			sub = nil
+		}
+		selected[fn] = sub
+	}
+	return selected
+}
+
+// prune returns copy of reply value with fields not selected by mask zeroed, repeated and map values
+// pruned element by element. Reply itself not changed, selected values shared with it.
+func (mask fieldMask) prune(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		if pb, ok := v.Interface().(proto.Message); ok {
+			return reflect.ValueOf(mask.pruneProto(pb.ProtoReflect()).Interface())
+		}
+		nv := reflect.New(v.Type().Elem())
+		nv.Elem().Set(mask.prune(v.Elem()))
+		return nv
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for idx := 0; idx < v.Len(); idx++ {
+			nv.Index(idx).Set(mask.prune(v.Index(idx)))
+		}
+		return nv
+	case reflect.Array:
+		nv := reflect.New(v.Type()).Elem()
+		for idx := 0; idx < v.Len(); idx++ {
+			nv.Index(idx).Set(mask.prune(v.Index(idx)))
+		}
+		return nv
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		nv := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			nv.SetMapIndex(iter.Key(), mask.prune(iter.Value()))
+		}
+		return nv
+	case reflect.Struct:
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(v)
+		selected := mask.structFields(v.Type())
+		for fn, f := range jsonFields(v.Type()) {
+			sub, ok := selected[fn]
+			if ok && sub == nil {
+				// whole field selected
+				continue
+			}
+			fv, settable := copyFieldByIndex(nv, f.index)
+			switch {
+			case !settable:
+			case ok:
+				fv.Set(sub.prune(fv))
+			default:
+				fv.SetZero()
+			}
+		}
+		return nv
+	}
+
+	return v
+}
+
+// copyFieldByIndex returns settable field of struct copy, embedded struct pointers on the way
+// replaced by copies, so original struct not changed
+func copyFieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for idx, fidx := range index {
+		if idx > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() || !v.CanSet() {
+				return reflect.Value{}, false
+			}
+			nv := reflect.New(v.Type().Elem())
+			nv.Elem().Set(v.Elem())
+			v.Set(nv)
+			v = nv.Elem()
+		}
+		v = v.Field(fidx)
+	}
+	return v, v.CanSet()
+}
+
+// pruneProto returns new message with fields selected by mask, selected values shared with original
+func (mask fieldMask) pruneProto(m protoreflect.Message) protoreflect.Message {
+	md := m.Descriptor()
+	selected := make(map[protoreflect.FieldNumber]fieldMask, len(mask))
+	for name, sub := range mask {
+		fd := queryField(md, name)
+		if fd == nil {
+			continue
+		}
+		if _, dup := selected[fd.Number()]; dup {
+			// field selected by proto and json names, keep it whole
+			sub = nil
+		}
+		selected[fd.Number()] = sub
+	}
+
+	nm := m.New()
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		sub, ok := selected[fd.Number()]
+		switch {
+		case !ok:
+		case sub == nil || fd.Message() == nil:
+			nm.Set(fd, v)
+		case fd.IsList():
+			list := nm.Mutable(fd).List()
+			for idx := 0; idx < v.List().Len(); idx++ {
+				list.Append(protoreflect.ValueOfMessage(sub.pruneProto(v.List().Get(idx).Message())))
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				nm.Set(fd, v)
+				break
+			}
+			mp := nm.Mutable(fd).Map()
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				mp.Set(k, protoreflect.ValueOfMessage(sub.pruneProto(mv.Message())))
+				return true
+			})
+		default:
+			nm.Set(fd, protoreflect.ValueOfMessage(sub.pruneProto(v.Message())))
+		}
+		return true
+	})
+
+	return nm
+}
+
+var (
+	typeOfJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// jsonFiltered reports whether json of type t, structs and slices or maps of them, filtered by mask.
+// Types marshalled by themselves and proto messages, which pruned and marshalled without unpopulated
+// fields, not filtered.
+func jsonFiltered(t reflect.Type) bool {
+	for t != nil {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		pt := reflect.PointerTo(t)
+		if pt.Implements(typeOfJSONMarshaler) || pt.Implements(typeOfTextMarshaler) || pt.Implements(typeOfProtoMessage) {
+			return false
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			return true
+		case reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// filterJSON drops keys not selected by mask from reply marshalled like encoding/json,
+// so zeroed fields without omitempty not sent. Kept values copied as is in single pass over buf.
+func (mask fieldMask) filterJSON(buf []byte, t reflect.Type) ([]byte, error) {
+	if !jsonFiltered(t) {
+		return buf, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	out := bytes.NewBuffer(make([]byte, 0, len(buf)))
+	if err := mask.filterValue(dec, out, t); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// filterValue copies next json value from dec to out, dropping object keys not selected by mask
+func (mask fieldMask) filterValue(dec *json.Decoder, out *bytes.Buffer, t reflect.Type) error {
+	if mask == nil || !jsonFiltered(t) {
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return err
+		}
+		out.Write(val)
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('['):
+		return mask.filterArray(dec, out, t)
+	case json.Delim('{'):
+		return mask.filterObject(dec, out, t)
+	}
+
+	// null or scalar value
+	buf, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	out.Write(buf)
+
+	return nil
+}
+
+// filterArray copies elements of json array which opening bracket already read
+func (mask fieldMask) filterArray(dec *json.Decoder, out *bytes.Buffer, t reflect.Type) error {
+	var et reflect.Type
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		et = t.Elem()
+	}
+
+	out.WriteByte('[')
+	for idx := 0; dec.More(); idx++ {
+		if idx > 0 {
+			out.WriteByte(',')
+		}
+		if err := mask.filterValue(dec, out, et); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	out.WriteByte(']')
+
+	return nil
+}
+
+// filterObject copies selected keys of json object which opening brace already read,
+// struct fields selected by mask, map values filtered by same mask
+func (mask fieldMask) filterObject(dec *json.Decoder, out *bytes.Buffer, t reflect.Type) error {
+	var selected map[string]fieldMask
+	if t.Kind() == reflect.Struct {
+		selected = mask.structFields(t)
+	}
+
+	out.WriteByte('{')
+	for n := 0; dec.More(); {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+
+		sub, vt, keep := mask, reflect.Type(nil), true
+		switch t.Kind() {
+		case reflect.Map:
+			vt = t.Elem()
+		case reflect.Struct:
+			var fn string
+			var f jsonField
+			if fn, f, keep = lookupJSONField(t, key); keep {
+				sub, keep = selected[fn]
+				vt = f.typ
+			}
+		}
+		if !keep {
+			if err = dec.Decode(&json.RawMessage{}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if n > 0 {
+			out.WriteByte(',')
+		}
+		n++
+		kbuf, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		out.Write(kbuf)
+		out.WriteByte(':')
+		if err = sub.filterValue(dec, out, vt); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	out.WriteByte('}')
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+type FieldMaskTestItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type fieldMaskTestEmbedded struct {
+	Owner string `json:"owner,omitempty"`
+}
+
+type FieldMaskTestResponse struct {
+	fieldMaskTestEmbedded
+	Items  []*FieldMaskTestItem         `json:"items,omitempty"`
+	Labels map[string]FieldMaskTestItem `json:"labels,omitempty"`
+	Parent *FieldMaskTestItem           `json:"parent,omitempty"`
+	Total  int                          `json:"total,omitempty"`
+}
+
+func newFieldMaskTestResponse() *FieldMaskTestResponse {
+	return &FieldMaskTestResponse{
+		fieldMaskTestEmbedded: fieldMaskTestEmbedded{Owner: "o"},
+		Items:                 []*FieldMaskTestItem{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}},
+		Labels:                map[string]FieldMaskTestItem{"x": {ID: 3, Name: "c"}},
+		Parent:                &FieldMaskTestItem{ID: 4, Name: "d"},
+		Total:                 2,
+	}
+}
+
+func TestParseFieldMask(t *testing.T) {
+	mask, err := parseFieldMask(" name, items.id,items,parent.id,parent.name ")
+	require.NoError(t, err)
+	require.Equal(t, fieldMask{"name": nil, "items": nil, "parent": {"id": nil, "name": nil}}, mask)
+
+	mask, err = parseFieldMask(" , ")
+	require.NoError(t, err)
+	require.Nil(t, mask)
+
+	_, err = parseFieldMask("items..id")
+	require.Error(t, err)
+}
+
+func TestFieldMaskPrune(t *testing.T) {
+	rt := reflect.TypeOf(&FieldMaskTestResponse{})
+
+	mask, err := parseFieldMask("owner,items.id,labels.name,parent")
+	require.NoError(t, err)
+	require.NoError(t, mask.validate(rt, ""))
+
+	// reply not changed, pruned copy returned
+	rsp := newFieldMaskTestResponse()
+	pruned := mask.prune(reflect.ValueOf(rsp)).Interface()
+	require.Equal(t, &FieldMaskTestResponse{
+		fieldMaskTestEmbedded: fieldMaskTestEmbedded{Owner: "o"},
+		Items:                 []*FieldMaskTestItem{{ID: 1}, {ID: 2}},
+		Labels:                map[string]FieldMaskTestItem{"x": {Name: "c"}},
+		Parent:                &FieldMaskTestItem{ID: 4, Name: "d"},
+	}, pruned)
+	require.Equal(t, newFieldMaskTestResponse(), rsp)
+
+	for fields, errText := range map[string]string{
+		"items.nmae": `unknown field "items.nmae"`,
+		"total.x":    `unknown field "total.x"`,
+		"bogus":      `unknown field "bogus"`,
+	} {
+		mask, err = parseFieldMask(fields)
+		require.NoError(t, err)
+		require.ErrorContains(t, mask.validate(rt, ""), errText, fields)
+	}
+
+	// proto messages use proto or json names
+	pt := reflect.TypeOf(&typepb.Type{})
+	mask, err = parseFieldMask("name,fields.name,fields.json_name,source_context")
+	require.NoError(t, err)
+	require.NoError(t, mask.validate(pt, ""))
+
+	msg := &typepb.Type{
+		Name:          "t",
+		Fields:        []*typepb.Field{{Name: "a", JsonName: "a", Number: 1}, {Name: "b", Number: 2}},
+		Oneofs:        []string{"o"},
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "f"},
+	}
+	pmsg := mask.prune(reflect.ValueOf(msg)).Interface().(*typepb.Type)
+	require.True(t, proto.Equal(&typepb.Type{
+		Name:          "t",
+		Fields:        []*typepb.Field{{Name: "a", JsonName: "a"}, {Name: "b"}},
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "f"},
+	}, pmsg), pmsg.String())
+	require.Equal(t, int32(2), msg.Fields[1].Number)
+	require.Equal(t, []string{"o"}, msg.Oneofs)
+
+	// unselected keys dropped from json even without omitempty
+	mask, err = parseFieldMask("owner,items.name,labels.id")
+	require.NoError(t, err)
+	buf, err := mask.filterJSON([]byte(`{"owner":"o","items":[{"id":0,"name":"a"},null],`+
+		`"labels":{"x":{"id":3,"name":""}},"parent":null,"total":0}`), rt)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"owner":"o","items":[{"name":"a"},null],"labels":{"x":{"id":3}}}`, string(buf))
+
+	// json of proto messages not filtered, codec omits unpopulated fields of pruned message
+	mask, err = parseFieldMask("fields.json_name")
+	require.NoError(t, err)
+	pbuf := []byte(`{"name":"t","fields":[{"name":"a","jsonName":"a"}]}`)
+	buf, err = mask.filterJSON(pbuf, pt)
+	require.NoError(t, err)
+	require.Equal(t, pbuf, buf)
+
+	mask, err = parseFieldMask("sourceContext.fileName,fields.bogus")
+	require.NoError(t, err)
+	require.ErrorContains(t, mask.validate(pt, ""), `unknown field "fields.bogus"`)
+}
+
+type FieldMaskTestHandler struct{}
+
+func (*FieldMaskTestHandler) Get(_ context.Context, _ *EchoRequest, rsp *FieldMaskTestResponse) error {
+	*rsp = *newFieldMaskTestResponse()
+	return nil
+}
+
+func TestServerPartialResponse(t *testing.T) {
+	srv := newTestServer(t, PartialResponse(true))
+	require.NoError(t, srv.Handle(srv.NewHandler(&FieldMaskTestHandler{}, HandlerEndpoints([]EndpointMetadata{
+		{Name: "FieldMaskTest.Get", Method: http.MethodGet, Path: "/v1/view"},
+	}))))
+	c := startTestServer(t, srv)
+
+	get := func(query, fields string) (int, string) {
+		req := c.request(http.MethodGet, "/v1/view"+query, nil)
+		if fields != "" {
+			req.Header.Set("X-Fields", fields)
+		}
+		rsp, body := c.do(req)
+		return rsp.StatusCode, body
+	}
+
+	code, body := get("?fields=items.name,total", "")
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"items":[{"name":"a"},{"name":"b"}],"total":2}`, body)
+
+	code, body = get("", "parent.id")
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"parent":{"id":4}}`, body)
+
+	code, body = get("", "")
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"owner":"o","items":[{"id":1,"name":"a"},{"id":2,"name":"b"}],`+
+		`"labels":{"x":{"id":3,"name":"c"}},"parent":{"id":4,"name":"d"},"total":2}`, body)
+
+	code, body = get("?fields=items.nmae", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, `invalid fields: unknown field "items.nmae"`, body)
+}
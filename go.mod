@@ -3,12 +3,15 @@ module go.unistack.org/micro-server-http/v4
 go 1.24.0
 
 require (
+	github.com/quic-go/quic-go v0.54.0
 	github.com/stretchr/testify v1.10.0
 	go.unistack.org/micro-client-http/v4 v4.1.0
 	go.unistack.org/micro-codec-yaml/v4 v4.1.0
 	go.unistack.org/micro-proto/v4 v4.1.0
 	go.unistack.org/micro/v4 v4.1.8
 	golang.org/x/net v0.39.0
+	golang.org/x/sys v0.32.0
+	google.golang.org/protobuf v1.36.6
 )
 
 require (
@@ -19,10 +22,15 @@ require (
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/matoous/go-nanoid v1.5.1 // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
+	github.com/quic-go/qpack v0.5.1 // indirect
 	github.com/spf13/cast v1.7.1 // indirect
-	golang.org/x/sys v0.32.0 // indirect
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/sync v0.13.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250428153025-10db94c68c34 // indirect
 	google.golang.org/grpc v1.72.0 // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
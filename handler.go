@@ -21,6 +21,7 @@ import (
 	"go.unistack.org/micro/v4/tracer"
 	rhttp "go.unistack.org/micro/v4/util/http"
 	rflutil "go.unistack.org/micro/v4/util/reflect"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
@@ -37,6 +38,7 @@ type patHandler struct {
 	mtype *methodType
 	rcvr  reflect.Value
 	name  string
+	route Route
 }
 
 type httpHandler struct {
@@ -44,6 +46,8 @@ type httpHandler struct {
 	hd       interface{}
 	handlers *rhttp.Trie
 	name     string
+	hosts    []string
+	routes   []Route
 	sopts    server.Options
 }
 
@@ -59,6 +63,8 @@ func (h *httpHandler) Options() server.HandlerOptions {
 	return h.opts
 }
 
+// HTTPHandlerFunc returns http.HandlerFunc that serves requests by registered handlers endpoints
+// with handler and endpoint middlewares, handler only checked to have endpoint signature
 func (h *Server) HTTPHandlerFunc(handler interface{}) (http.HandlerFunc, error) {
 	if handler == nil {
 		return nil, fmt.Errorf("invalid handler specified: %v", handler)
@@ -96,239 +102,170 @@ func (h *Server) HTTPHandlerFunc(handler interface{}) (http.HandlerFunc, error)
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		ct := DefaultContentType
-		if htype := r.Header.Get(metadata.HeaderContentType); htype != "" {
-			ct = htype
+		rm := h.resolveRoute(r)
+		if ri := rm.routeInfo(); ri != nil {
+			r = r.WithContext(newRouteInfoContext(r.Context(), ri))
 		}
 
-		ctx := context.WithValue(r.Context(), rspStatusCodeKey{}, &rspStatusCodeVal{})
-		ctx = context.WithValue(ctx, rspMetadataKey{}, &rspMetadataVal{m: metadata.New(0)})
-
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			md = metadata.New(len(r.Header) + 8)
-		}
-		for k, v := range r.Header {
-			md[k] = append(md[k], v...)
-		}
-
-		md["RemoteAddr"] = append(md["RemoteAddr"], r.RemoteAddr)
-		md["Method"] = append(md["Method"], r.Method)
-		md["URL"] = append(md["URL"], r.URL.String())
-		md["Proto"] = append(md["Proto"], r.Proto)
-		md["Content-Length"] = append(md["Content-Length"], fmt.Sprintf("%d", r.ContentLength))
-		md["Transfer-Encoding"] = append(md["Transfer-Encoding"], r.TransferEncoding...)
-		md["Host"] = append(md["Host"], r.Host)
-		md["RequestURI"] = append(md["RequestURI"], r.RequestURI)
-		if r.TLS != nil {
-			md["TLS"] = append(md["TLS"], "true")
-			md["TLS-ALPN"] = append(md["TLS-ALPN"], r.TLS.NegotiatedProtocol)
-			md["TLS-ServerName"] = append(md["TLS-ServerName"], r.TLS.ServerName)
-		}
-
-		ctx = metadata.NewIncomingContext(ctx, md)
-		ctx = metadata.NewOutgoingContext(ctx, metadata.New(0))
-
-		path := r.URL.Path
-
-		if r.Body != nil {
-			defer r.Body.Close()
-		}
-
-		matches := make(map[string]interface{})
-		var match bool
-		var hldr *patHandler
-		var handler *httpHandler
-
-		for _, shdlr := range h.handlers {
-			hdlr := shdlr.(*httpHandler)
-			fh, mp, err := hdlr.handlers.Search(r.Method, path)
-			if err == nil {
-				match = true
-				for k, v := range mp {
-					matches[k] = v
-				}
-				hldr = fh.(*patHandler)
-				handler = hdlr
-				break
-			} else if err == rhttp.ErrMethodNotAllowed && !h.registerRPC {
-				w.WriteHeader(http.StatusMethodNotAllowed)
-				_, _ = w.Write([]byte("not matching route found"))
-				return
-			}
-		}
-
-		if !match && h.registerRPC {
-			for _, microMethod := range md.Get(metadata.HeaderEndpoint) {
-				serviceMethod := strings.Split(microMethod, ".")
-				if len(serviceMethod) == 2 {
-					if shdlr, ok := h.handlers[serviceMethod[0]]; ok {
-						hdlr := shdlr.(*httpHandler)
-						fh, mp, err := hdlr.handlers.Search(http.MethodPost, "/"+microMethod)
-						if err == nil {
-							// match = true
-							for k, v := range mp {
-								matches[k] = v
-							}
-							hldr = fh.(*patHandler)
-							handler = hdlr
-						}
-					}
-				}
-			}
+		ctx, md, info, _ := h.newRequestContext(r)
+		if h.answerRoute(ctx, w, r, rm) {
+			return
 		}
-
-		// get fields from url values
-		if len(r.URL.RawQuery) > 0 {
-			umd, cerr := rflutil.URLMap(r.URL.RawQuery)
-			if cerr != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				_, _ = w.Write([]byte(cerr.Error()))
-				return
-			}
-			for k, v := range umd {
-				matches[k] = v
-			}
+		if rm.hldr == nil {
+			h.errorHandler(ctx, nil, w, r, fmt.Errorf("not matching route found"), http.StatusNotFound)
+			return
 		}
-
-		cf, err := h.newCodec(ct)
-		if err != nil {
+		if _, err := h.newCodec(requestContentType(r)); err != nil {
 			w.WriteHeader(http.StatusUnsupportedMediaType)
 			return
 		}
-
-		var argv, replyv reflect.Value
-
-		// Decode the argument value.
-		argIsValue := false // if true, need to indirect before calling.
-		if hldr.mtype.ArgType.Kind() == reflect.Ptr {
-			argv = reflect.New(hldr.mtype.ArgType.Elem())
-		} else {
-			argv = reflect.New(hldr.mtype.ArgType)
-			argIsValue = true
-		}
-
-		if argIsValue {
-			argv = argv.Elem()
+		if rm.path != r.URL.Path {
+			r = withPath(r, rm.path)
 		}
 
-		// reply value
-		replyv = reflect.New(hldr.mtype.ReplyType.Elem())
-
-		function := hldr.mtype.method.Func
-		var returnValues []reflect.Value
+		info.Route, info.PathParams = rm.hldr.route.Path, rm.params
+		h.serveEndpointRoute(w, r.WithContext(ctx), rm, md, nil)
+	}, nil
+}
 
-		if r.Body != nil {
-			var buf []byte
-			buf, err = io.ReadAll(r.Body)
-			if err != nil && err != io.EOF {
-				h.errorHandler(ctx, handler, w, r, err, http.StatusInternalServerError)
-				return
-			}
+// routeMatch is request route resolved before server middlewares, so they can use RouteInfoFromContext
+type routeMatch struct {
+	err error
+	// handler endpoint
+	handler *httpHandler
+	hldr    *patHandler
+	// fallback is http.Handler passed to Handle, like some muxer
+	fallback http.Handler
+	// pr is path handler
+	pr     *pathRoute
+	params map[string]string
+	// path is request path relative to base path and normalized
+	path   string
+	method string
+	host   string
+	allow  []string
+	// status is non zero if request answered without handler: bad path, redirect, OPTIONS or not allowed method
+	status int
+}
 
-			if err = cf.Unmarshal(buf, argv.Interface()); err != nil {
-				h.errorHandler(ctx, handler, w, r, err, http.StatusBadRequest)
-				return
-			}
-		}
+// routeInfo returns info about matched route, nil if no route matched
+func (rm *routeMatch) routeInfo() *RouteInfo {
+	switch {
+	case rm.hldr != nil:
+		return rm.handler.routeInfo(rm.hldr, rm.method, rm.host)
+	case rm.pr != nil:
+		return &RouteInfo{Route: rm.pr.route}
+	}
+	return nil
+}
 
-		matches = rflutil.FlattenMap(matches)
-		if err = rflutil.Merge(argv.Interface(), matches, rflutil.SliceAppend(true), rflutil.Tags([]string{"protobuf", "json"})); err != nil {
-			h.errorHandler(ctx, handler, w, r, err, http.StatusBadRequest)
-			return
-		}
+// resolveRoute matches request to handler endpoint, fallback handler or path handler
+func (h *Server) resolveRoute(r *http.Request) *routeMatch {
+	rt := h.routeTable()
+	rm := &routeMatch{path: r.URL.Path, method: r.Method, host: requestHost(r.Host)}
 
-		hr := &rpcRequest{
-			codec:       cf,
-			service:     handler.sopts.Name,
-			contentType: ct,
-			method:      fmt.Sprintf("%s.%s", hldr.name, hldr.mtype.method.Name),
-			endpoint:    fmt.Sprintf("%s.%s", hldr.name, hldr.mtype.method.Name),
-			payload:     argv.Interface(),
-			header:      md,
-		}
+	if !strings.HasPrefix(rm.path, "/") {
+		rm.status, rm.err = http.StatusBadRequest, fmt.Errorf("path must starts with /")
+		return rm
+	}
 
-		// define the handler func
-		fn := func(fctx context.Context, req server.Request, rsp interface{}) (err error) {
-			returnValues = function.Call([]reflect.Value{hldr.rcvr, hldr.mtype.prepareContext(fctx), argv, reflect.ValueOf(rsp)})
+	np, ok := stripBasePath(h.basePath, rm.path)
+	if !ok {
+		rm.status, rm.err = http.StatusNotFound, fmt.Errorf("not matching route found")
+		return rm
+	}
 
-			// The return value for the method is an error.
-			if rerr := returnValues[0].Interface(); rerr != nil {
-				err = rerr.(error)
-			}
+	np, redirect := h.normalizePath(rt, rm.host, r.Method, np)
+	rm.path = np
+	if redirect {
+		rm.status = http.StatusMovedPermanently
+		return rm
+	}
 
-			return err
+	// answer OPTIONS if no explicit route for it
+	if r.Method == http.MethodOptions && !rt.has(rm.host, http.MethodOptions, rm.path) {
+		if rm.allow = rt.allowed(rm.host, rm.path); len(rm.allow) > 0 {
+			rm.status = http.StatusNoContent
+			return rm
 		}
+	}
 
-		// wrap the handler func
-		h.opts.Hooks.EachPrev(func(hook options.Hook) {
-			if h, ok := hook.(server.HookHandler); ok {
-				fn = h(fn)
-			}
-		})
+	// HEAD served by GET route, body discarded by http server
+	rm.method = rt.searchMethod(rm.host, r.Method, rm.path)
 
-		if ct == "application/x-www-form-urlencoded" {
-			cf, err = h.newCodec(DefaultContentType)
-			if err != nil {
-				h.errorHandler(ctx, handler, w, r, err, http.StatusInternalServerError)
-				return
-			}
-			ct = DefaultContentType
-		}
+	hdlr, fh, mp, err := rt.searchHandlers(rm.host, rm.method, rm.path)
+	if err == nil {
+		rm.handler, rm.hldr, rm.params = hdlr, fh.(*patHandler), mp
+		return rm
+	}
+	notAllowed := err == rhttp.ErrMethodNotAllowed
 
-		scode := int(200)
-		appErr := fn(ctx, hr, replyv.Interface())
+	pr, mp, perr := rt.searchPath(rm.host, rm.method, rm.path)
 
-		w.Header().Set(metadata.HeaderContentType, ct)
-		for k, v := range getResponseMetadata(ctx) {
-			w.Header()[k] = v
-		}
-		if nct := w.Header().Get(metadata.HeaderContentType); nct != ct {
-			if cf, err = h.newCodec(nct); err != nil {
-				h.errorHandler(ctx, nil, w, r, err, http.StatusInternalServerError)
-				return
+	if h.registerRPC {
+		for _, microMethod := range r.Header.Values(metadata.HeaderEndpoint) {
+			serviceMethod := strings.Split(microMethod, ".")
+			if len(serviceMethod) != 2 {
+				continue
 			}
-		}
-
-		var buf []byte
-		if appErr != nil {
-			switch verr := appErr.(type) {
-			case *errors.Error:
-				scode = int(verr.Code)
-				buf, err = cf.Marshal(verr)
-			case *Error:
-				buf, err = cf.Marshal(verr.err)
-			default:
-				buf, err = cf.Marshal(appErr)
+			if shdlr, ok := rt.handlers[serviceMethod[0]]; ok && hostRank(shdlr.(*httpHandler).hosts, rm.host) >= 0 {
+				hdlr := shdlr.(*httpHandler)
+				if fh, _, err := hdlr.handlers.Search(http.MethodPost, "/"+microMethod); err == nil {
+					rm.method = http.MethodPost
+					rm.handler, rm.hldr = hdlr, fh.(*patHandler)
+					return rm
+				}
 			}
-		} else {
-			buf, err = cf.Marshal(replyv.Interface())
 		}
-
-		if err != nil && handler.sopts.Logger.V(logger.ErrorLevel) {
-			handler.sopts.Logger.Error(handler.sopts.Context, "handler error", err)
-			return
+	} else {
+		// path may be served by other handler or path handler, so 405 only after all checked
+		if perr == nil {
+			notAllowed = false
+		} else if perr == rhttp.ErrMethodNotAllowed {
+			notAllowed = true
 		}
-
-		if nscode := GetResponseStatusCode(ctx); nscode != 0 {
-			scode = nscode
+		if notAllowed {
+			rm.status, rm.err = http.StatusMethodNotAllowed, fmt.Errorf("not matching route found")
+			rm.allow = rt.allowed(rm.host, rm.path)
+			return rm
 		}
-		w.WriteHeader(scode)
+	}
 
-		if _, cerr := w.Write(buf); cerr != nil {
-			handler.sopts.Logger.Error(ctx, "write failed", cerr)
+	if h.hd != nil {
+		if hdlr, ok := h.hd.Handler().(http.Handler); ok {
+			rm.fallback = hdlr
+			return rm
 		}
-	}, nil
-}
+	}
 
-func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ct := DefaultContentType
-	if htype := r.Header.Get(metadata.HeaderContentType); htype != "" {
-		ct = htype
+	if perr == nil {
+		rm.pr, rm.params = pr, mp
 	}
 
-	ts := time.Now()
+	return rm
+}
+
+// answerRoute answers request resolved without handler, returns false if request must be served by route
+func (h *Server) answerRoute(ctx context.Context, w http.ResponseWriter, r *http.Request, rm *routeMatch) bool {
+	switch rm.status {
+	case 0:
+		return false
+	case http.StatusMovedPermanently:
+		redirectPath(w, r, h.basePath+rm.path)
+	case http.StatusNoContent:
+		w.Header().Set("Allow", strings.Join(rm.allow, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	case http.StatusMethodNotAllowed:
+		w.Header().Set("Allow", strings.Join(rm.allow, ", "))
+		h.errorHandler(ctx, nil, w, r, rm.err, rm.status)
+	default:
+		h.errorHandler(ctx, nil, w, r, rm.err, rm.status)
+	}
+	return true
+}
 
+// newRequestContext returns request context with response state, request info, principal and metadata
+func (h *Server) newRequestContext(r *http.Request) (context.Context, metadata.Metadata, *RequestInfo, *Principal) {
 	ctx := context.WithValue(r.Context(), rspStatusCodeKey{}, &rspStatusCodeVal{})
 	ctx = context.WithValue(ctx, rspMetadataKey{}, &rspMetadataVal{m: metadata.New(0)})
 
@@ -340,116 +277,107 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		md[k] = append(md[k], v...)
 	}
 
-	md["RemoteAddr"] = append(md["RemoteAddr"], r.RemoteAddr)
-	if r.TLS != nil {
-		md["Scheme"] = append(md["Scheme"], "https")
-	} else {
-		md["Scheme"] = append(md["Scheme"], "http")
-	}
-	md["Method"] = append(md["Method"], r.Method)
-	md["URL"] = append(md["URL"], r.URL.String())
-	md["Proto"] = append(md["Proto"], r.Proto)
-	md["Content-Length"] = append(md["Content-Length"], fmt.Sprintf("%d", r.ContentLength))
-	if len(r.TransferEncoding) > 0 {
-		md["Transfer-Encoding"] = append(md["Transfer-Encoding"], r.TransferEncoding...)
+	info := newRequestInfo(r)
+	info.BasePath = h.basePath
+	ctx = newRequestInfoContext(ctx, info)
+	if h.legacyMetadata {
+		for k, v := range info.legacyMetadata() {
+			md[k] = v
+		}
 	}
-	md["Host"] = append(md["Host"], r.Host)
-	md["RequestURI"] = append(md["RequestURI"], r.RequestURI)
+	ctx, principal := withPrincipal(ctx, md, r.TLS)
 
 	ctx = metadata.NewIncomingContext(ctx, md)
 	ctx = metadata.NewOutgoingContext(ctx, metadata.New(0))
 
-	path := r.URL.Path
-	if !strings.HasPrefix(path, "/") {
-		h.errorHandler(ctx, nil, w, r, fmt.Errorf("path must starts with /"), http.StatusBadRequest)
-		return
-	}
+	return ctx, md, info, principal
+}
 
-	matches := make(map[string]interface{})
+func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// route resolved before server middlewares, so they can use RouteInfoFromContext
+	rm := h.resolveRoute(r)
+	if ri := rm.routeInfo(); ri != nil {
+		r = r.WithContext(newRouteInfoContext(r.Context(), ri))
+	}
 
-	var match bool
-	var hldr *patHandler
-	var handler *httpHandler
+	if len(h.middlewares) == 0 {
+		h.serveRoute(w, r, rm)
+		return
+	}
 
-	for _, shdlr := range h.handlers {
-		hdlr := shdlr.(*httpHandler)
-		fh, mp, err := hdlr.handlers.Search(r.Method, path)
-		if err == nil {
-			match = true
-			for k, v := range mp {
-				matches[k] = v
+	chain(h.middlewares, http.HandlerFunc(func(w http.ResponseWriter, mr *http.Request) {
+		// middlewares like http.StripPrefix may rewrite request, so route resolved again for it
+		if rerouted(r, mr) {
+			rm = h.resolveRoute(mr)
+			if ri := rm.routeInfo(); ri != nil {
+				mr = mr.WithContext(newRouteInfoContext(mr.Context(), ri))
 			}
-			hldr = fh.(*patHandler)
-			handler = hdlr
-			break
-		} else if err == rhttp.ErrMethodNotAllowed && !h.registerRPC {
-			h.errorHandler(ctx, nil, w, r, fmt.Errorf("not matching route found"), http.StatusMethodNotAllowed)
-			return
 		}
-	}
+		h.serveRoute(w, mr, rm)
+	})).ServeHTTP(w, r)
+}
 
-	if !match && h.registerRPC {
-		for _, microMethod := range md.Get(metadata.HeaderEndpoint) {
-			serviceMethod := strings.Split(microMethod, ".")
-			if len(serviceMethod) == 2 {
-				if shdlr, ok := h.handlers[serviceMethod[0]]; ok {
-					hdlr := shdlr.(*httpHandler)
-					fh, mp, err := hdlr.handlers.Search(http.MethodPost, "/"+microMethod)
-					if err == nil {
-						match = true
-						for k, v := range mp {
-							matches[k] = v
-						}
-						hldr = fh.(*patHandler)
-						handler = hdlr
-					}
+// rerouted reports whether request changed by middleware in a way that affects route resolution
+func rerouted(r, mr *http.Request) bool {
+	return r.Method != mr.Method || r.Host != mr.Host || r.URL.Path != mr.URL.Path ||
+		r.Header.Get(metadata.HeaderEndpoint) != mr.Header.Get(metadata.HeaderEndpoint)
+}
 
-				}
-			}
-		}
+// serveRoute serves request by resolved route
+func (h *Server) serveRoute(w http.ResponseWriter, r *http.Request, rm *routeMatch) {
+	ts := time.Now()
+
+	ctx, md, info, principal := h.newRequestContext(r)
+	if h.answerRoute(ctx, w, r, rm) {
+		return
 	}
+	if rm.path != r.URL.Path {
+		r = withPath(r, rm.path)
+	}
+	info.PathParams = rm.params
 
 	var sp tracer.Span
-	if !match && h.hd != nil {
-		if hdlr, ok := h.hd.Handler().(http.Handler); ok {
-			endpointName := fmt.Sprintf("%s.%s", hldr.name, hldr.mtype.method.Name)
-			if !slices.Contains(tracer.DefaultSkipEndpoints, endpointName) {
-				ctx, sp = h.opts.Tracer.Start(ctx, "rpc-server",
-					tracer.WithSpanKind(tracer.SpanKindServer),
-					tracer.WithSpanLabels(
-						"endpoint", endpointName,
-					),
-				)
-				defer func() {
-					n := GetResponseStatusCode(ctx)
-					if s, _ := sp.Status(); s != tracer.SpanStatusError && n > 399 {
-						sp.SetStatus(tracer.SpanStatusError, http.StatusText(n))
-					}
-					sp.Finish()
-				}()
+	if rm.fallback != nil {
+		endpointName := r.URL.Path
+		if !slices.Contains(tracer.DefaultSkipEndpoints, endpointName) {
+			ctx, sp = h.opts.Tracer.Start(ctx, "rpc-server",
+				tracer.WithSpanKind(tracer.SpanKindServer),
+				tracer.WithSpanLabels(
+					"endpoint", endpointName,
+				),
+			)
+			if principal != nil {
+				sp.AddLabels("principal", principal.Name())
 			}
+			defer func() {
+				n := GetResponseStatusCode(ctx)
+				if s, _ := sp.Status(); s != tracer.SpanStatusError && n > 399 {
+					sp.SetStatus(tracer.SpanStatusError, http.StatusText(n))
+				}
+				sp.Finish()
+			}()
+		}
 
-			if !slices.Contains(meter.DefaultSkipEndpoints, endpointName) {
-				h.opts.Meter.Counter(semconv.ServerRequestInflight, "endpoint", endpointName, "server", "http").Inc()
-
-				defer func() {
-					n := GetResponseStatusCode(ctx)
-					if n > 399 {
-						h.opts.Meter.Counter(semconv.ServerRequestTotal, "endpoint", endpointName, "server", "http", "status", "success", "code", strconv.Itoa(n)).Inc()
-					} else {
-						h.opts.Meter.Counter(semconv.ServerRequestTotal, "endpoint", endpointName, "server", "http", "status", "failure", "code", strconv.Itoa(n)).Inc()
-					}
-					te := time.Since(ts)
-					h.opts.Meter.Summary(semconv.ServerRequestLatencyMicroseconds, "endpoint", endpointName, "server", "http").Update(te.Seconds())
-					h.opts.Meter.Histogram(semconv.ServerRequestDurationSeconds, "endpoint", endpointName, "server", "http").Update(te.Seconds())
-					h.opts.Meter.Counter(semconv.ServerRequestInflight, "endpoint", endpointName, "server", "http").Dec()
-				}()
-			}
+		if !slices.Contains(meter.DefaultSkipEndpoints, endpointName) {
+			h.opts.Meter.Counter(semconv.ServerRequestInflight, "endpoint", endpointName, "server", "http").Inc()
 
-			hdlr.ServeHTTP(w, r.WithContext(ctx))
-			return
+			defer func() {
+				n := GetResponseStatusCode(ctx)
+				if n > 399 {
+					h.opts.Meter.Counter(semconv.ServerRequestTotal, "endpoint", endpointName, "server", "http", "status", "success", "code", strconv.Itoa(n)).Inc()
+				} else {
+					h.opts.Meter.Counter(semconv.ServerRequestTotal, "endpoint", endpointName, "server", "http", "status", "failure", "code", strconv.Itoa(n)).Inc()
+				}
+				te := time.Since(ts)
+				h.opts.Meter.Summary(semconv.ServerRequestLatencyMicroseconds, "endpoint", endpointName, "server", "http").Update(te.Seconds())
+				h.opts.Meter.Histogram(semconv.ServerRequestDurationSeconds, "endpoint", endpointName, "server", "http").Update(te.Seconds())
+				h.opts.Meter.Counter(semconv.ServerRequestInflight, "endpoint", endpointName, "server", "http").Dec()
+			}()
 		}
-	} else if !match {
+
+		rm.fallback.ServeHTTP(w, r.WithContext(ctx))
+		return
+	} else if rm.hldr == nil {
 		// check for http.HandlerFunc handlers
 		if !slices.Contains(tracer.DefaultSkipEndpoints, r.URL.Path) {
 			ctx, sp = h.opts.Tracer.Start(ctx, "rpc-server",
@@ -459,6 +387,9 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					"server", "http",
 				),
 			)
+			if principal != nil {
+				sp.AddLabels("principal", principal.Name())
+			}
 
 			defer func() {
 				if n := GetResponseStatusCode(ctx); n > 399 {
@@ -469,14 +400,16 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				sp.Finish()
 			}()
 		}
-		if ph, _, err := h.pathHandlers.Search(r.Method, r.URL.Path); err == nil {
-			ph.(http.HandlerFunc)(w, r.WithContext(ctx))
+		if rm.pr != nil {
+			info.Route = rm.pr.route.Path
+			chain(h.pathMiddlewares[rm.pr.route.Method+" "+rm.pr.route.Path], rm.pr.handler).ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 		h.errorHandler(ctx, nil, w, r, fmt.Errorf("not matching route found"), http.StatusNotFound)
 		return
 	}
 
+	handler, hldr := rm.handler, rm.hldr
 	endpointName := fmt.Sprintf("%s.%s", hldr.name, hldr.mtype.method.Name)
 
 	topts := []tracer.SpanOption{
@@ -492,6 +425,9 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx, sp = h.opts.Tracer.Start(ctx, "rpc-server", topts...)
+	if principal != nil {
+		sp.AddLabels("principal", principal.Name())
+	}
 
 	if !slices.Contains(meter.DefaultSkipEndpoints, handler.name) {
 		defer func() {
@@ -519,13 +455,41 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		sp.Finish()
 	}()
 
-	// get fields from url values
-	if len(r.URL.RawQuery) > 0 {
+	info.Route = hldr.route.Path
+	h.serveEndpointRoute(w, r.WithContext(ctx), rm, md, sp)
+}
+
+// serveEndpointRoute serves endpoint through handler and endpoint middlewares
+func (h *Server) serveEndpointRoute(w http.ResponseWriter, r *http.Request, rm *routeMatch, md metadata.Metadata, sp tracer.Span) {
+	ct := requestContentType(r)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serveEndpoint(w, r, rm.handler, rm.hldr, md, rm.params, ct, sp)
+	})
+	chain(rm.handler.middlewares(rm.hldr.route.Name), next).ServeHTTP(w, r)
+}
+
+// serveEndpoint decodes request, calls endpoint and writes response, context taken from request
+// so handler and endpoint middlewares can change it
+func (h *Server) serveEndpoint(w http.ResponseWriter, r *http.Request, handler *httpHandler, hldr *patHandler,
+	md metadata.Metadata, params map[string]string, ct string, sp tracer.Span,
+) {
+	ctx := r.Context()
+
+	matches := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		matches[k] = v
+	}
+
+	// get fields from url values, proto messages bound by bindQuery
+	if len(r.URL.RawQuery) > 0 && !isProtoMessage(hldr.mtype.ArgType) {
 		umd, cerr := rflutil.URLMap(r.URL.RawQuery)
 		if cerr != nil {
 			h.errorHandler(ctx, handler, w, r, cerr, http.StatusBadRequest)
 			return
 		}
+		if h.partialResponse {
+			delete(umd, fieldsQueryParam)
+		}
 		for k, v := range umd {
 			matches[k] = v
 		}
@@ -571,13 +535,34 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err = cf.Unmarshal(buf, argv.Interface()); err != nil {
+		if h.strictDecoding(handler) && isJSONContentType(ct) {
+			err = decodeStrictJSON(buf, argv.Interface())
+		} else {
+			err = cf.Unmarshal(buf, argv.Interface())
+		}
+		if err != nil {
 			h.errorHandler(ctx, handler, w, r, err, http.StatusBadRequest)
 			return
 		}
 	}
 
-	if len(matches) > 0 {
+	if pb, ok := argv.Interface().(proto.Message); ok {
+		// bound like grpc-gateway, path parameters override body, query not binds body and path fields
+		if err = bindPath(pb, params); err != nil {
+			h.errorHandler(ctx, handler, w, r, err, http.StatusBadRequest)
+			return
+		}
+		if len(r.URL.RawQuery) > 0 {
+			reserved := h.reservedQueryParams()
+			for k := range params {
+				reserved = append(reserved, k)
+			}
+			if err = bindQuery(pb, r.URL.RawQuery, h.strictQuery, hldr.route.Body, reserved...); err != nil {
+				h.errorHandler(ctx, handler, w, r, err, http.StatusBadRequest)
+				return
+			}
+		}
+	} else if len(matches) > 0 {
 		matches = rflutil.FlattenMap(matches)
 		if err = rflutil.Merge(argv.Interface(), matches, rflutil.SliceAppend(true), rflutil.Tags([]string{"protobuf", "json"})); err != nil {
 			h.errorHandler(ctx, handler, w, r, err, http.StatusBadRequest)
@@ -585,6 +570,19 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if err = bindRequest(r, argv.Interface(), params, handler.bindings()); err != nil {
+		h.errorHandler(ctx, handler, w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	var mask fieldMask
+	if h.partialResponse {
+		if mask, err = requestFieldMask(r, hldr.mtype.ReplyType); err != nil {
+			h.errorHandler(ctx, handler, w, r, err, http.StatusBadRequest)
+			return
+		}
+	}
+
 	hr := &rpcRequest{
 		codec:       cf,
 		service:     handler.sopts.Name,
@@ -627,7 +625,16 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	scode := int(200)
-	appErr := fn(ctx, hr, replyv.Interface())
+	rw := newRawResponseWriter(ctx, w)
+	appErr := fn(newRawContext(ctx, r, rw), hr, replyv.Interface())
+
+	if rw.written(ctx, appErr) {
+		if appErr != nil && handler.sopts.Logger.V(logger.ErrorLevel) {
+			handler.sopts.Logger.Error(ctx, "handler error after response written", appErr)
+		}
+		writeResponseTrailers(ctx, w)
+		return
+	}
 
 	w.Header().Set(metadata.HeaderContentType, ct)
 	for k, v := range getResponseMetadata(ctx) {
@@ -652,7 +659,13 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			buf, err = cf.Marshal(appErr)
 		}
 	} else {
+		if mask != nil {
+			replyv = mask.prune(replyv)
+		}
 		buf, err = cf.Marshal(replyv.Interface())
+		if err == nil && mask != nil && isJSONContentType(w.Header().Get(metadata.HeaderContentType)) {
+			buf, err = mask.filterJSON(buf, replyv.Type())
+		}
 	}
 
 	if err != nil {
@@ -664,9 +677,21 @@ func (h *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		scode = nscode
 	}
 
+	if val, ok := ctx.Value(rspMetadataKey{}).(*rspMetadataVal); ok {
+		val.declareTrailers(w.Header())
+	}
 	w.WriteHeader(scode)
 
 	if _, cerr := w.Write(buf); cerr != nil {
 		handler.sopts.Logger.Error(ctx, "respoonse write error", cerr)
 	}
+	writeResponseTrailers(ctx, w)
+}
+
+// requestContentType returns request content type or default one
+func requestContentType(r *http.Request) string {
+	if ct := r.Header.Get(metadata.HeaderContentType); ct != "" {
+		return ct
+	}
+	return DefaultContentType
 }
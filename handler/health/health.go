@@ -2,8 +2,10 @@ package health_handler
 
 import (
 	"context"
+	"encoding/json"
 
 	codecpb "go.unistack.org/micro-proto/v4/codec"
+	v4 "go.unistack.org/micro-server-http/v4"
 	"go.unistack.org/micro/v4/errors"
 )
 
@@ -14,7 +16,7 @@ type Handler struct {
 }
 
 type (
-	CheckFunc func(context.Context) error
+	CheckFunc = v4.HealthCheckFunc
 	Option    func(*Options)
 )
 
@@ -24,6 +26,20 @@ type Stater interface {
 	Health() bool
 }
 
+// RegisterStater reports service registration state in Healthy output
+type RegisterStater interface {
+	Registered() bool
+}
+
+// HealthChecker runs service own health checks in Healthy, like server HealthChecks option
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+type healthyResponse struct {
+	Registered bool `json:"registered"`
+}
+
 type Options struct {
 	Version      string
 	Name         string
@@ -86,12 +102,29 @@ func (h *Handler) Healthy(ctx context.Context, req *codecpb.Frame, rsp *codecpb.
 		}
 	}
 
+	for _, s := range h.opts.Staters {
+		if hc, ok := s.(HealthChecker); ok {
+			if err = hc.CheckHealth(ctx); err != nil {
+				return errors.ServiceUnavailable(h.opts.Name, "%v", err)
+			}
+		}
+	}
+
 	for _, fn := range h.opts.HealthChecks {
 		if err = fn(ctx); err != nil {
 			return errors.ServiceUnavailable(h.opts.Name, "%v", err)
 		}
 	}
 
+	for _, s := range h.opts.Staters {
+		if rs, ok := s.(RegisterStater); ok {
+			if rsp.Data, err = json.Marshal(healthyResponse{Registered: rs.Registered()}); err != nil {
+				return errors.InternalServerError(h.opts.Name, "%v", err)
+			}
+			break
+		}
+	}
+
 	return nil
 }
 
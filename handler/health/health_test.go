@@ -0,0 +1,40 @@
+package health_handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	codecpb "go.unistack.org/micro-proto/v4/codec"
+)
+
+type testStater struct {
+	registered bool
+	err        error
+}
+
+func (s *testStater) Live() bool       { return true }
+func (s *testStater) Ready() bool      { return true }
+func (s *testStater) Health() bool     { return true }
+func (s *testStater) Registered() bool { return s.registered }
+
+func (s *testStater) CheckHealth(context.Context) error { return s.err }
+
+func TestHealthyRegistered(t *testing.T) {
+	s := &testStater{}
+	h := NewHandler(Name("svc"), Service(s))
+
+	rsp := &codecpb.Frame{}
+	require.NoError(t, h.Healthy(context.Background(), &codecpb.Frame{}, rsp))
+	require.JSONEq(t, `{"registered":false}`, string(rsp.Data))
+
+	s.registered = true
+	rsp = &codecpb.Frame{}
+	require.NoError(t, h.Healthy(context.Background(), &codecpb.Frame{}, rsp))
+	require.JSONEq(t, `{"registered":true}`, string(rsp.Data))
+
+	// server health checks reported without registering them in handler
+	s.err = errors.New("db down")
+	require.Error(t, h.Healthy(context.Background(), &codecpb.Frame{}, &codecpb.Frame{}))
+}
@@ -0,0 +1,48 @@
+package routes_handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	yamlcodec "go.unistack.org/micro-codec-yaml/v4"
+	httpsrv "go.unistack.org/micro-server-http/v4"
+)
+
+// Router provides list of server routes, implemented by httpsrv.Server
+type Router interface {
+	Routes() []httpsrv.Route
+}
+
+// NewHandler returns handler that lists server routes in json,
+// yaml returned if format=yaml query param passed or Accept header contains yaml
+func NewHandler(rt Router) http.HandlerFunc {
+	c := yamlcodec.NewCodec()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var buf []byte
+		var err error
+
+		ct := "application/json"
+		if r.URL.Query().Get("format") == "yaml" || strings.Contains(r.Header.Get("Accept"), "yaml") {
+			ct = "application/yaml"
+			buf, err = c.Marshal(rt.Routes())
+		} else {
+			buf, err = json.Marshal(rt.Routes())
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", ct)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf)
+	}
+}
@@ -0,0 +1,60 @@
+package routes_handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	yamlcodec "go.unistack.org/micro-codec-yaml/v4"
+	httpsrv "go.unistack.org/micro-server-http/v4"
+)
+
+func TestHandler(t *testing.T) {
+	srv := httpsrv.NewServer(
+		httpsrv.PathHandler(http.MethodGet, "/items/{id}", func(http.ResponseWriter, *http.Request) {}),
+	)
+	require.NoError(t, srv.Init())
+	h := NewHandler(srv)
+
+	hasItems := func(routes []httpsrv.Route) bool {
+		for _, r := range routes {
+			if r.Name == "" && r.Method == http.MethodGet && r.Path == "/items/{id}" {
+				return true
+			}
+		}
+		return false
+	}
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/routes", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var routes []httpsrv.Route
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &routes))
+	require.True(t, hasItems(routes), w.Body.String())
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/routes?format=yaml", nil),
+		func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/routes", nil)
+			r.Header.Set("Accept", "application/yaml")
+			return r
+		}(),
+	} {
+		w = httptest.NewRecorder()
+		h(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+		require.True(t, strings.Contains(w.Body.String(), "path: /items/{id}"), w.Body.String())
+		routes = nil
+		require.NoError(t, yamlcodec.NewCodec().Unmarshal(w.Body.Bytes(), &routes))
+		require.True(t, hasItems(routes), w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/routes", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -5,10 +5,32 @@ import (
 	"net/http"
 
 	yamlcodec "go.unistack.org/micro-codec-yaml/v4"
-	rutil "go.unistack.org/micro/v4/util/reflect"
+	v4 "go.unistack.org/micro-server-http/v4"
 )
 
-// Handler append to generated swagger data from dst map[string]interface{}
+// BasePath returns dst for Handler that sets openapi servers url to base path,
+// server BasePath applied by Handler automatically
+func BasePath(basePath string) map[string]interface{} {
+	return map[string]interface{}{
+		"servers": []interface{}{map[string]interface{}{"url": basePath}},
+	}
+}
+
+// merge copies src into dst, nested maps merged, other values replaced
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		sm, sok := v.(map[string]interface{})
+		dm, dok := dst[k].(map[string]interface{})
+		if sok && dok {
+			merge(dm, sm)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// Handler append to generated swagger data from dst map[string]interface{},
+// openapi servers url set to server base path if it not specified in dst
 var Handler = func(dst map[string]interface{}, fsys fs.FS) http.HandlerFunc {
 	c := yamlcodec.NewCodec()
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -29,25 +51,29 @@ var Handler = func(dst map[string]interface{}, fsys fs.FS) http.HandlerFunc {
 			return
 		}
 
-		if dst == nil {
+		var basePath string
+		if ri, ok := v4.RequestInfoFromContext(r.Context()); ok {
+			basePath = ri.BasePath
+		}
+
+		if dst == nil && basePath == "" {
 			w.WriteHeader(http.StatusOK)
 			_, _ = w.Write(buf)
 			return
 		}
 
-		var src interface{}
+		src := make(map[string]interface{})
 
-		if err = c.Unmarshal(buf, src); err != nil {
+		if err = c.Unmarshal(buf, &src); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			_, _ = w.Write([]byte(err.Error()))
 			return
 		}
 
-		if err = rutil.Merge(src, dst); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			_, _ = w.Write([]byte(err.Error()))
-			return
+		if basePath != "" {
+			merge(src, BasePath(basePath))
 		}
+		merge(src, dst)
 
 		if buf, err = c.Marshal(src); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,273 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+const (
+	// listenFdsStart is the first inherited file descriptor, after stdin, stdout and stderr
+	listenFdsStart = 3
+)
+
+var (
+	// DefaultHandoffEnv specifies env variable with number of listeners passed from parent process on upgrade
+	DefaultHandoffEnv = "MICRO_SERVER_HTTP_LISTEN_FDS"
+
+	// ErrReusePortNotSupported returned when SO_REUSEPORT not available on platform
+	ErrReusePortNotSupported = errors.New("SO_REUSEPORT not supported")
+)
+
+var inherited = &inheritedListeners{}
+
+// inheritedListeners holds listeners and packet conns passed by systemd socket activation or parent process,
+// each listener taken only once
+type inheritedListeners struct {
+	err  error
+	lns  []net.Listener
+	pcs  []net.PacketConn
+	mu   sync.Mutex
+	once sync.Once
+}
+
+func (il *inheritedListeners) load() {
+	il.once.Do(func() {
+		var nfds int
+		var err error
+
+		switch {
+		case os.Getenv("LISTEN_FDS") != "":
+			// systemd socket activation, fds passed only to the process with LISTEN_PID
+			if pid, perr := strconv.Atoi(os.Getenv("LISTEN_PID")); perr != nil || pid != os.Getpid() {
+				return
+			}
+			nfds, err = strconv.Atoi(os.Getenv("LISTEN_FDS"))
+			_ = os.Unsetenv("LISTEN_PID")
+			_ = os.Unsetenv("LISTEN_FDS")
+			_ = os.Unsetenv("LISTEN_FDNAMES")
+		case os.Getenv(DefaultHandoffEnv) != "":
+			nfds, err = strconv.Atoi(os.Getenv(DefaultHandoffEnv))
+			_ = os.Unsetenv(DefaultHandoffEnv)
+		default:
+			return
+		}
+
+		if err != nil {
+			il.err = fmt.Errorf("invalid number of inherited listeners: %w", err)
+			return
+		}
+
+		for fd := listenFdsStart; fd < listenFdsStart+nfds; fd++ {
+			f := os.NewFile(uintptr(fd), "listener"+strconv.Itoa(fd))
+			// net.FileListener and net.FilePacketConn dup fd with close on exec flag
+			if ln, err := net.FileListener(f); err == nil {
+				il.lns = append(il.lns, ln)
+			} else if pc, err := net.FilePacketConn(f); err == nil {
+				// udp socket of http3 server
+				il.pcs = append(il.pcs, pc)
+			}
+			_ = f.Close()
+		}
+	})
+}
+
+// take returns inherited listener matched network and address or nil
+func (il *inheritedListeners) take(network, address string) (net.Listener, error) {
+	il.load()
+
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	if il.err != nil {
+		return nil, il.err
+	}
+
+	for i, ln := range il.lns {
+		if matchListenerAddr(ln.Addr(), network, address) {
+			il.lns = append(il.lns[:i], il.lns[i+1:]...)
+			return ln, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// takePacket returns inherited packet conn matched network and address or nil
+func (il *inheritedListeners) takePacket(network, address string) (net.PacketConn, error) {
+	il.load()
+
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	if il.err != nil {
+		return nil, il.err
+	}
+
+	for i, pc := range il.pcs {
+		if matchListenerAddr(pc.LocalAddr(), network, address) {
+			il.pcs = append(il.pcs[:i], il.pcs[i+1:]...)
+			return pc, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// matchListenerAddr checks that listener bound to requested address,
+// unspecified or empty host matches only unspecified listener address
+func matchListenerAddr(addr net.Addr, network, address string) bool {
+	switch v := addr.(type) {
+	case *net.UnixAddr:
+		return network == "unix" && v.Name == address
+	case *net.TCPAddr:
+		return network != "unix" && matchIPPort(v.IP, v.Port, address)
+	case *net.UDPAddr:
+		return network != "unix" && matchIPPort(v.IP, v.Port, address)
+	}
+
+	return false
+}
+
+func matchIPPort(vip net.IP, vport int, address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if p, err := net.LookupPort("tcp", port); err != nil || p != vport || p == 0 {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if host == "" || (ip != nil && ip.IsUnspecified()) {
+		return vip == nil || vip.IsUnspecified()
+	}
+	if ip == nil {
+		// hostname like localhost
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return false
+		}
+		for _, ip := range ips {
+			if ip.Equal(vip) {
+				return true
+			}
+		}
+		return false
+	}
+	return ip.Equal(vip)
+}
+
+// listen returns inherited listener if enabled and available, otherwise creates new one
+func (h *Server) listen(network, address string) (net.Listener, error) {
+	h.mu.RLock()
+	config := h.opts
+	h.mu.RUnlock()
+
+	if v, ok := config.Context.Value(inheritListenersKey{}).(bool); ok && v {
+		ln, err := inherited.take(network, address)
+		if err != nil {
+			return nil, err
+		}
+		if ln != nil {
+			return ln, nil
+		}
+	}
+
+	// remove stale socket file left by unclean shutdown, socket with live listener kept
+	if network == "unix" {
+		if fi, err := os.Stat(address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			conn, derr := net.Dial("unix", address)
+			if derr == nil {
+				_ = conn.Close()
+				return nil, fmt.Errorf("unix socket %s already in use", address)
+			}
+			if !errors.Is(derr, syscall.ECONNREFUSED) {
+				return nil, derr
+			}
+			if err = os.Remove(address); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	lc := net.ListenConfig{}
+	if v, ok := config.Context.Value(reusePortKey{}).(bool); ok && v && network != "unix" {
+		lc.Control = reusePortControl
+	}
+
+	return lc.Listen(config.Context, network, address)
+}
+
+// listenPacket returns inherited packet conn if enabled and available, otherwise creates new one
+func (h *Server) listenPacket(network, address string) (net.PacketConn, error) {
+	h.mu.RLock()
+	config := h.opts
+	h.mu.RUnlock()
+
+	if v, ok := config.Context.Value(inheritListenersKey{}).(bool); ok && v {
+		pc, err := inherited.takePacket(network, address)
+		if err != nil {
+			return nil, err
+		}
+		if pc != nil {
+			return pc, nil
+		}
+	}
+
+	lc := net.ListenConfig{}
+	if v, ok := config.Context.Value(reusePortKey{}).(bool); ok && v {
+		lc.Control = reusePortControl
+	}
+
+	return lc.ListenPacket(config.Context, network, address)
+}
+
+// Upgrade starts new copy of running binary with the same args and passes active listeners
+// and http3 udp socket to it. The new process must enable InheritListeners option, after it ready to serve,
+// old one can be stopped via Stop, that drains in-flight requests.
+// Both processes read the same udp socket until old one stopped, so QUIC packets of its connections
+// may be received by new process and such connections broken.
+func (h *Server) Upgrade() (*os.Process, error) {
+	h.mu.RLock()
+	lns := h.rawListeners
+	pcs := h.rawPacketConns
+	h.mu.RUnlock()
+
+	if len(lns) == 0 {
+		return nil, fmt.Errorf("server not started")
+	}
+
+	conns := make([]syscall.Conn, 0, len(pcs)+len(lns))
+	for _, pc := range pcs {
+		sc, ok := pc.(syscall.Conn)
+		if !ok {
+			return nil, fmt.Errorf("packet conn %s can't be passed to other process", pc.LocalAddr())
+		}
+		conns = append(conns, sc)
+	}
+
+	for _, ln := range lns {
+		sc, ok := ln.(syscall.Conn)
+		if !ok {
+			return nil, fmt.Errorf("listener %s can't be passed to other process", ln.Addr())
+		}
+		// socket file must survive closing of listener in old process
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+		conns = append(conns, sc)
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	// new process must outlive old one, so it not bound to any context
+	env := append(os.Environ(), DefaultHandoffEnv+"="+strconv.Itoa(len(conns)))
+	return startProcess(path, append([]string{path}, os.Args[1:]...), env, conns)
+}
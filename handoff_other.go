@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package http
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+func startProcess(path string, args, env []string, conns []syscall.Conn) (*os.Process, error) {
+	return nil, fmt.Errorf("upgrade not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/server"
+)
+
+// upgradeTestEnv holds address of parent server, set when test binary started by Upgrade
+const upgradeTestEnv = "MICRO_SERVER_HTTP_UPGRADE_TEST"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(upgradeTestEnv) != "" {
+		os.Exit(runUpgradeTestChild())
+	}
+	os.Exit(m.Run())
+}
+
+// runUpgradeTestChild serves /pid on listeners inherited from TestServerUpgrade until SIGTERM
+func runUpgradeTestChild() int {
+	crt, err := tls.LoadX509KeyPair(os.Getenv(upgradeTestEnv+"_CERT"), os.Getenv(upgradeTestEnv+"_KEY"))
+	if err != nil {
+		return 1
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM)
+
+	srv := newUpgradeTestServer(os.Getenv(upgradeTestEnv), crt, InheritListeners(true))
+	if err = srv.Init(); err != nil {
+		return 1
+	}
+	// fails with address in use if tcp listener or http3 udp socket not inherited
+	if err = srv.Start(); err != nil {
+		return 1
+	}
+	if err = os.WriteFile(os.Getenv(upgradeTestEnv+"_READY"), nil, 0o600); err != nil {
+		return 1
+	}
+
+	<-sig
+	if err = srv.Stop(); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func newUpgradeTestServer(addr string, crt tls.Certificate, opts ...server.Option) *Server {
+	return NewServer(append([]server.Option{
+		server.Address(addr),
+		server.TLSConfig(&tls.Config{Certificates: []tls.Certificate{crt}}),
+		HTTP3Server(&http3.Server{}),
+		PathHandler(http.MethodGet, "/pid", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(strconv.Itoa(os.Getpid())))
+		}),
+	}, opts...)...)
+}
+
+func TestMatchListenerAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    net.Addr
+		network string
+		address string
+		match   bool
+	}{
+		{"tcp exact", &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, "tcp", "127.0.0.1:8080", true},
+		{"tcp port mismatch", &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, "tcp", "127.0.0.1:8081", false},
+		{"tcp unspecified", &net.TCPAddr{IP: net.IPv6unspecified, Port: 8080}, "tcp", ":8080", true},
+		{"tcp unspecified v4", &net.TCPAddr{IP: net.IPv6unspecified, Port: 8080}, "tcp", "0.0.0.0:8080", true},
+		{"tcp specific vs unspecified", &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, "tcp", ":8080", false},
+		{"tcp random port", &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, "tcp", "127.0.0.1:0", false},
+		{"tcp vs unix", &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, "unix", "127.0.0.1:8080", false},
+		{"unix", &net.UnixAddr{Net: "unix", Name: "/run/srv.sock"}, "unix", "/run/srv.sock", true},
+		{"unix other path", &net.UnixAddr{Net: "unix", Name: "/run/srv.sock"}, "unix", "/run/other.sock", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.match, matchListenerAddr(tt.addr, tt.network, tt.address))
+		})
+	}
+}
+
+func TestInheritedListenersTake(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	il := &inheritedListeners{lns: []net.Listener{ln}}
+	il.once.Do(func() {})
+
+	v, err := il.take("tcp", "127.0.0.1:1")
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	v, err = il.take("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	require.Equal(t, ln, v)
+
+	// listener taken only once
+	v, err = il.take("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	require.Nil(t, v)
+}
+
+func TestServerReusePort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT semantics checked only on linux")
+	}
+
+	srv := NewServer(server.Address("127.0.0.1:0"), ReusePort(true))
+	require.NoError(t, srv.Init())
+
+	ln1, err := srv.listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln1.Close()
+
+	ln2, err := srv.listen("tcp", ln1.Addr().String())
+	require.NoError(t, err)
+	defer ln2.Close()
+
+	srv = NewServer(server.Address("127.0.0.1:0"))
+	require.NoError(t, srv.Init())
+
+	_, err = srv.listen("tcp", ln1.Addr().String())
+	require.Error(t, err)
+}
+
+func TestServerUpgrade(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("listeners handoff checked only on linux")
+	}
+
+	crt, leaf := newTestCertificate(t, "127.0.0.1")
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	readyFile := filepath.Join(dir, "ready")
+	key, err := x509.MarshalPKCS8PrivateKey(crt.PrivateKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Certificate[0]}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+
+	srv := newUpgradeTestServer("127.0.0.1:0", crt)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+
+	addr := srv.Options().Address
+	t.Setenv(upgradeTestEnv, addr)
+	t.Setenv(upgradeTestEnv+"_CERT", certFile)
+	t.Setenv(upgradeTestEnv+"_KEY", keyFile)
+	t.Setenv(upgradeTestEnv+"_READY", readyFile)
+
+	proc, err := srv.Upgrade()
+	require.NoError(t, err)
+	defer func() {
+		_ = proc.Kill()
+		_, _ = proc.Wait()
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(readyFile)
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, srv.Stop())
+
+	get := func(c *http.Client) string {
+		rsp, err := c.Get("https://" + addr + "/pid")
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		buf, err := io.ReadAll(rsp.Body)
+		require.NoError(t, err)
+		return string(buf)
+	}
+
+	// tcp and http3 served by child after parent stopped
+	tr := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	defer tr.CloseIdleConnections()
+	require.Equal(t, strconv.Itoa(proc.Pid), get(&http.Client{Transport: tr}))
+
+	h3 := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	defer h3.Close()
+	require.Equal(t, strconv.Itoa(proc.Pid), get(&http.Client{Transport: h3}))
+
+	tr.CloseIdleConnections()
+	require.NoError(t, proc.Signal(syscall.SIGTERM))
+	state, err := proc.Wait()
+	require.NoError(t, err)
+	require.True(t, state.Success())
+}
+
+func TestServerListenUnixStale(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "srv.sock")
+
+	srv := NewServer(server.Address("127.0.0.1:0"))
+	require.NoError(t, srv.Init())
+
+	ln, err := srv.listen("unix", sock)
+	require.NoError(t, err)
+
+	// socket with live listener not removed
+	_, err = srv.listen("unix", sock)
+	require.Error(t, err)
+
+	// socket file left after unclean shutdown removed
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, ln.Close())
+	_, err = os.Stat(sock)
+	require.NoError(t, err)
+
+	ln, err = srv.listen("unix", sock)
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+}
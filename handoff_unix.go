@@ -0,0 +1,55 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package http
+
+import (
+	"os"
+	"syscall"
+)
+
+// startProcess starts binary with sockets passed as fds 3, 4 and so on.
+// Raw socket fds passed instead of os.File, because os.File.Fd switches socket shared with child
+// to blocking mode and accept in current process may hang.
+func startProcess(path string, args, env []string, conns []syscall.Conn) (*os.Process, error) {
+	fds := make([]uintptr, 0, len(conns)+3)
+	fds = append(fds, os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd())
+
+	var dups []int
+	defer func() {
+		for _, fd := range dups {
+			_ = syscall.Close(fd)
+		}
+	}()
+
+	for _, c := range conns {
+		rc, err := c.SyscallConn()
+		if err != nil {
+			return nil, err
+		}
+		var dfd int
+		var derr error
+		// dup under fork lock so fd not leaked to other child processes
+		syscall.ForkLock.RLock()
+		err = rc.Control(func(fd uintptr) {
+			if dfd, derr = syscall.Dup(int(fd)); derr == nil {
+				syscall.CloseOnExec(dfd)
+			}
+		})
+		syscall.ForkLock.RUnlock()
+		if err == nil {
+			err = derr
+		}
+		if err != nil {
+			return nil, err
+		}
+		dups = append(dups, dfd)
+		fds = append(fds, uintptr(dfd))
+	}
+
+	pid, err := syscall.ForkExec(path, args, &syscall.ProcAttr{Env: env, Files: fds})
+	if err != nil {
+		return nil, err
+	}
+
+	return os.FindProcess(pid)
+}
@@ -9,16 +9,21 @@ import (
 	"net"
 	"net/http"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/quic-go/quic-go/http3"
 	"go.unistack.org/micro/v4/codec"
 	"go.unistack.org/micro/v4/logger"
+	"go.unistack.org/micro/v4/metadata"
 	"go.unistack.org/micro/v4/register"
 	"go.unistack.org/micro/v4/server"
 	rhttp "go.unistack.org/micro/v4/util/http"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"golang.org/x/net/netutil"
 )
 
@@ -27,18 +32,42 @@ var _ server.Server = (*Server)(nil)
 type Server struct {
 	hd           server.Handler
 	rsvc         *register.Service
-	handlers     map[string]server.Handler
 	exit         chan chan error
 	errorHandler func(context.Context, server.Handler, http.ResponseWriter, *http.Request, error, int)
-	pathHandlers *rhttp.Trie
 	opts         server.Options
 	stateLive    *atomic.Uint32
 	stateReady   *atomic.Uint32
 	stateHealth  *atomic.Uint32
 	registerRPC  bool
-	mu           sync.RWMutex
-	registered   bool
-	init         bool
+	basePath     string
+	// middlewares applied after route resolved, so they can use RouteInfoFromContext
+	middlewares     []func(http.Handler) http.Handler
+	pathMiddlewares map[string][]func(http.Handler) http.Handler
+	// legacyMetadata injects request properties to incoming metadata
+	legacyMetadata bool
+	// strictQuery rejects unknown query parameters
+	strictQuery bool
+	// strictDecode rejects unknown fields, duplicate keys and trailing data in json request bodies
+	strictDecode bool
+	// partialResponse prunes reply to fields selected by request
+	partialResponse bool
+	// path normalization
+	redirectTrailingSlash bool
+	cleanPath             bool
+	caseInsensitive       bool
+	mu                    sync.RWMutex
+	rawListeners          []net.Listener
+	rawPacketConns        []net.PacketConn
+	routes                atomic.Pointer[routeTable]
+	done                  chan struct{}
+	err                   error
+	state                 atomic.Uint32
+	lmu                   sync.Mutex
+	http3Address          string
+	// listenerAdvertise is address of listener marked Advertise, used in register if server Advertise not set
+	listenerAdvertise string
+	registered        bool
+	init              bool
 }
 
 func (h *Server) newCodec(ct string) (codec.Codec, error) {
@@ -74,28 +103,57 @@ func (h *Server) Init(opts ...server.Option) error {
 	if fn, ok := h.opts.Context.Value(errorHandlerKey{}).(func(ctx context.Context, s server.Handler, w http.ResponseWriter, r *http.Request, err error, status int)); ok && fn != nil {
 		h.errorHandler = fn
 	}
-	if h.handlers == nil {
-		h.handlers = make(map[string]server.Handler)
-	}
-	if h.pathHandlers == nil {
-		h.pathHandlers = rhttp.NewTrie()
-	}
-
 	if v, ok := h.opts.Context.Value(registerRPCHandlerKey{}).(bool); ok {
 		h.registerRPC = v
 	}
+	if v, ok := h.opts.Context.Value(legacyRequestMetadataKey{}).(bool); ok {
+		h.legacyMetadata = v
+	}
+	if v, ok := h.opts.Context.Value(strictQueryKey{}).(bool); ok {
+		h.strictQuery = v
+	}
+	if v, ok := h.opts.Context.Value(strictDecodingKey{}).(bool); ok {
+		h.strictDecode = v
+	}
+	if v, ok := h.opts.Context.Value(partialResponseKey{}).(bool); ok {
+		h.partialResponse = v
+	}
+	if v, ok := h.opts.Context.Value(basePathKey{}).(string); ok {
+		h.basePath = normalizeBasePath(v)
+	}
+	if v, ok := h.opts.Context.Value(middlewareKey{}).([]func(http.Handler) http.Handler); ok {
+		h.middlewares = v
+	}
+	if v, ok := h.opts.Context.Value(pathMiddlewareKey{}).(map[string][]func(http.Handler) http.Handler); ok {
+		h.pathMiddlewares = v
+	}
+	if v, ok := h.opts.Context.Value(redirectTrailingSlashKey{}).(bool); ok {
+		h.redirectTrailingSlash = v
+	}
+	if v, ok := h.opts.Context.Value(cleanPathKey{}).(bool); ok {
+		h.cleanPath = v
+	}
+	if v, ok := h.opts.Context.Value(caseInsensitiveKey{}).(bool); ok {
+		h.caseInsensitive = v
+	}
+
+	phs, _ := h.opts.Context.Value(pathHandlerKey{}).(*pathHandlerVal)
+	h.mu.Unlock()
 
-	if phs, ok := h.opts.Context.Value(pathHandlerKey{}).(*pathHandlerVal); ok && phs.h != nil {
-		for pm, ps := range phs.h {
-			for pp, ph := range ps {
-				if err := h.pathHandlers.Insert([]string{pm}, pp, ph); err != nil {
-					h.mu.Unlock()
-					return err
+	if phs != nil && phs.h != nil {
+		if err := h.updateRoutes(func(rt *routeTable) error {
+			for host, ms := range phs.h {
+				for pm, ps := range ms {
+					for pp, ph := range ps {
+						rt.addPath(host, pm, pp, ph)
+					}
 				}
 			}
+			return nil
+		}); err != nil {
+			return err
 		}
 	}
-	h.mu.Unlock()
 
 	h.mu.RLock()
 	if err := h.opts.Register.Init(); err != nil {
@@ -146,15 +204,11 @@ func (h *Server) Handle(handler server.Handler) error {
 		return nil
 	}
 
-	// passed micro compat handler
-	h.mu.Lock()
-	if h.handlers == nil {
-		h.handlers = make(map[string]server.Handler)
-	}
-	h.handlers[handler.Name()] = handler
-	h.mu.Unlock()
-
-	return nil
+	// passed micro compat handler, it can be added while server running
+	return h.updateRoutes(func(rt *routeTable) error {
+		rt.handlers[handler.Name()] = handler
+		return nil
+	})
 }
 
 func (h *Server) NewHandler(handler interface{}, opts ...server.HandlerOption) server.Handler {
@@ -169,6 +223,12 @@ func (h *Server) NewHandler(handler interface{}, opts ...server.HandlerOption) s
 
 	tp := reflect.TypeOf(handler)
 
+	if v, ok := options.Context.Value(handlerHostsKey{}).([]string); ok {
+		for _, host := range v {
+			hdlr.hosts = append(hdlr.hosts, strings.ToLower(host))
+		}
+	}
+
 	registerCORS := false
 	if v, ok := options.Context.Value(registerCORSHandlerKey{}).(bool); ok && v {
 		registerCORS = true
@@ -213,10 +273,27 @@ func (h *Server) NewHandler(handler interface{}, opts ...server.HandlerOption) s
 
 		pattern := md["Path"]
 		for i := len(pattern) - 1; i >= 0; i-- {
-			if err := hdlr.handlers.Insert(methods, pattern[i], pth); err != nil {
+			rpth := pth.withRoute(Route{
+				Name:   hn,
+				Path:   pattern[i],
+				Body:   strings.Join(md["Body"], ""),
+				Stream: slices.Contains(md["Stream"], "true"),
+			})
+			if err := hdlr.handlers.Insert(methods, pattern[i], rpth); err != nil {
 				h.opts.Logger.Error(h.opts.Context, fmt.Sprintf("cant add handler for %v %s: index %d", methods, md["Path"], i))
 			}
 		}
+		for _, p := range pattern {
+			for _, m := range md["Method"] {
+				hdlr.routes = append(hdlr.routes, Route{
+					Name:   hn,
+					Method: m,
+					Path:   p,
+					Body:   strings.Join(md["Body"], ""),
+					Stream: slices.Contains(md["Stream"], "true"),
+				})
+			}
+		}
 
 		if h.registerRPC {
 			methods := []string{http.MethodPost}
@@ -224,9 +301,10 @@ func (h *Server) NewHandler(handler interface{}, opts ...server.HandlerOption) s
 				methods = append(methods, http.MethodOptions)
 			}
 
-			if err := hdlr.handlers.Insert(methods, "/"+hn, pth); err != nil {
+			if err := hdlr.handlers.Insert(methods, "/"+hn, pth.withRoute(Route{Name: hn, Path: "/" + hn, Body: "*"})); err != nil {
 				h.opts.Logger.Error(h.opts.Context, fmt.Sprintf("cant add rpc handler for http.MethodPost %s /%s", hn, hn))
 			}
+			hdlr.routes = append(hdlr.routes, Route{Name: hn, Method: http.MethodPost, Path: "/" + hn, Body: "*"})
 		}
 	}
 
@@ -273,9 +351,11 @@ func (h *Server) NewHandler(handler interface{}, opts ...server.HandlerOption) s
 			methods = append(methods, http.MethodOptions)
 		}
 
-		if err := hdlr.handlers.Insert(methods, md.Path, pth); err != nil {
+		rpth := pth.withRoute(Route{Name: hn, Path: md.Path, Body: md.Body, Stream: md.Stream})
+		if err := hdlr.handlers.Insert(methods, md.Path, rpth); err != nil {
 			h.opts.Logger.Error(h.opts.Context, fmt.Sprintf("cant add handler for %s %s", md.Method, md.Path))
 		}
+		hdlr.routes = append(hdlr.routes, Route{Name: hn, Method: md.Method, Path: md.Path, Body: md.Body, Stream: md.Stream})
 
 		if h.registerRPC {
 			methods := []string{http.MethodPost}
@@ -284,15 +364,71 @@ func (h *Server) NewHandler(handler interface{}, opts ...server.HandlerOption) s
 			}
 
 			h.opts.Logger.Info(h.opts.Context, fmt.Sprintf("register rpc handler for http.MethodPost %s /%s", hn, hn))
-			if err := hdlr.handlers.Insert(methods, "/"+hn, pth); err != nil {
+			if err := hdlr.handlers.Insert(methods, "/"+hn, pth.withRoute(Route{Name: hn, Path: "/" + hn, Body: "*"})); err != nil {
 				h.opts.Logger.Error(h.opts.Context, fmt.Sprintf("cant add rpc handler for http.MethodPost %s /%s", hn, hn))
 			}
+			hdlr.routes = append(hdlr.routes, Route{Name: hn, Method: http.MethodPost, Path: "/" + hn, Body: "*"})
 		}
 	}
 
 	return hdlr
 }
 
+// newRegisterService creates register service and fills node metadata with http specific info
+func (h *Server) newRegisterService() (*register.Service, error) {
+	h.mu.RLock()
+	http3Address := h.http3Address
+	listenerAdvertise := h.listenerAdvertise
+	advertise := h.opts.Advertise
+	h.mu.RUnlock()
+
+	var srv server.Server = h
+	if advertise == "" && listenerAdvertise != "" {
+		srv = &advertiseServer{Server: h, advertise: listenerAdvertise}
+	}
+
+	service, err := server.NewRegisterService(srv)
+	if err != nil {
+		return nil, err
+	}
+
+	rl := h.routeTable().list(false)
+	for i := range rl {
+		rl[i].Path = h.basePath + rl[i].Path
+	}
+
+	routes, err := routesMetadata(rl)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range service.Nodes {
+		if node.Metadata == nil {
+			node.Metadata = metadata.New(2)
+		}
+		if http3Address != "" {
+			node.Metadata.Set("http3", http3Address)
+		}
+		if routes != "" {
+			node.Metadata.Set(MetadataRoutes, routes)
+		}
+	}
+
+	return service, nil
+}
+
+// advertiseServer passes address of advertised listener to server.NewRegisterService
+type advertiseServer struct {
+	*Server
+	advertise string
+}
+
+func (s *advertiseServer) Options() server.Options {
+	opts := s.Server.Options()
+	opts.Advertise = s.advertise
+	return opts
+}
+
 func (h *Server) Register() error {
 	h.mu.RLock()
 	rsvc := h.rsvc
@@ -307,7 +443,7 @@ func (h *Server) Register() error {
 		return nil
 	}
 
-	service, err := server.NewRegisterService(h)
+	service, err := h.newRegisterService()
 	if err != nil {
 		return err
 	}
@@ -329,6 +465,9 @@ func (h *Server) Register() error {
 
 	// already registered? don't need to register subscribers
 	if registered {
+		h.mu.Lock()
+		h.rsvc = service
+		h.mu.Unlock()
 		return nil
 	}
 
@@ -346,7 +485,7 @@ func (h *Server) Deregister() error {
 	config := h.opts
 	h.mu.RUnlock()
 
-	service, err := server.NewRegisterService(h)
+	service, err := h.newRegisterService()
 	if err != nil {
 		return err
 	}
@@ -373,10 +512,59 @@ func (h *Server) Deregister() error {
 	return nil
 }
 
+// Start starts server, it does nothing if server already running.
+// Stopped server can be started again.
 func (h *Server) Start() error {
-	h.mu.RLock()
+	h.lmu.Lock()
+	defer h.lmu.Unlock()
+
+	if h.State() == StateRunning {
+		return nil
+	}
+
+	h.mu.Lock()
+	if h.State() == StateStopped {
+		// restart, previous run done closed
+		h.done = make(chan struct{})
+	}
+	done := h.done
+	h.err = nil
+	h.mu.Unlock()
+
+	h.state.Store(uint32(StateStarting))
+	if err := h.start(done); err != nil {
+		h.finish(done, err)
+		return err
+	}
+
+	// fatal serve error may stop server before it marked running
+	if !h.state.CompareAndSwap(uint32(StateStarting), uint32(StateRunning)) {
+		return h.Err()
+	}
+
+	return nil
+}
+
+func (h *Server) start(done chan struct{}) error {
+	h.mu.Lock()
 	config := h.opts
-	h.mu.RUnlock()
+	// addresses assigned on previous start are stale
+	h.http3Address = ""
+	h.listenerAdvertise = ""
+	h.mu.Unlock()
+
+	if err := runLifecycleHooks(config.Context, beforeStartKey{}, false); err != nil {
+		return err
+	}
+
+	var certs *certificateReloader
+	if ccfg, ok := config.Context.Value(tlsCertificatesKey{}).(CertificateConfig); ok {
+		var err error
+		if certs, err = newCertificateReloader(ccfg, config.Logger, config.Meter); err != nil {
+			return err
+		}
+		config.TLSConfig = certs.tlsConfig(config.TLSConfig)
+	}
 
 	// micro: config.Transport.Listen(config.Address)
 	var ts net.Listener
@@ -386,21 +574,43 @@ func (h *Server) Start() error {
 	} else {
 		var err error
 
-		// check the tls config for secure connect
-		if tc := config.TLSConfig; tc != nil {
-			ts, err = tls.Listen("tcp", config.Address, tc)
-			// otherwise just plain tcp listener
-		} else {
-			ts, err = net.Listen("tcp", config.Address)
+		// tls.Listen equivalent, but proxy protocol header sent before tls handshake
+		if ts, err = h.listen("tcp", config.Address); err != nil {
+			return err
 		}
+	}
+
+	rawListeners := []net.Listener{ts}
+
+	if ppcfg, ok := config.Context.Value(proxyProtocolKey{}).(ProxyProtocolConfig); ok {
+		pl, err := newProxyProtocolListener(ts, ppcfg)
 		if err != nil {
+			_ = ts.Close()
 			return err
 		}
+		ts = pl
+	}
+
+	h2s, _ := config.Context.Value(http2ServerKey{}).(*http2.Server)
+	useH2C, _ := config.Context.Value(h2cKey{}).(bool)
+	if useH2C && h2s == nil {
+		h2s = &http2.Server{}
+	}
+
+	// check the tls config for secure connect
+	if tc := config.TLSConfig; tc != nil && config.Listener == nil {
+		// advertise h2 via alpn, otherwise tuned http2 server never used
+		if h2s != nil && !slices.Contains(tc.NextProtos, http2.NextProtoTLS) {
+			tc = tc.Clone()
+			tc.NextProtos = append([]string{http2.NextProtoTLS, "http/1.1"}, tc.NextProtos...)
+		}
+		ts = tls.NewListener(ts, tc)
 	}
 
 	if config.MaxConn > 0 {
 		ts = netutil.LimitListener(ts, config.MaxConn)
 	}
+	ts = &onceCloseListener{Listener: ts}
 
 	if config.Logger.V(logger.InfoLevel) {
 		config.Logger.Info(config.Context, "Listening on "+ts.Addr().String())
@@ -410,6 +620,103 @@ func (h *Server) Start() error {
 	h.opts.Address = ts.Addr().String()
 	h.mu.Unlock()
 
+	var h3s *http3.Server
+	var h3c net.PacketConn
+
+	if v, ok := config.Context.Value(http3ServerKey{}).(*http3.Server); ok && v != nil {
+		if config.TLSConfig == nil {
+			_ = ts.Close()
+			return fmt.Errorf("http3 server requires tls config")
+		}
+
+		// by default use the same port as tcp listener
+		addr := v.Addr
+		if addr == "" {
+			addr = ts.Addr().String()
+		}
+
+		var err error
+		if h3c, err = h.listenPacket("udp", addr); err != nil {
+			_ = ts.Close()
+			return err
+		}
+
+		if config.Logger.V(logger.InfoLevel) {
+			config.Logger.Info(config.Context, "Listening http3 on "+h3c.LocalAddr().String())
+		}
+
+		// closed http3 server can't be used again, so copy settings on each start
+		h3s = &http3.Server{
+			Addr:               v.Addr,
+			Port:               v.Port,
+			TLSConfig:          v.TLSConfig,
+			QUICConfig:         v.QUICConfig,
+			Handler:            v.Handler,
+			EnableDatagrams:    v.EnableDatagrams,
+			MaxHeaderBytes:     v.MaxHeaderBytes,
+			AdditionalSettings: v.AdditionalSettings,
+			StreamHijacker:     v.StreamHijacker,
+			UniStreamHijacker:  v.UniStreamHijacker,
+			IdleTimeout:        v.IdleTimeout,
+			ConnContext:        v.ConnContext,
+			Logger:             v.Logger,
+		}
+		if h3s.TLSConfig == nil {
+			h3s.TLSConfig = http3.ConfigureTLSConfig(config.TLSConfig)
+		}
+
+		h.mu.Lock()
+		h.http3Address = h3c.LocalAddr().String()
+		h.mu.Unlock()
+	}
+
+	lcfgs, _ := config.Context.Value(listenersKey{}).([]ListenerConfig)
+	listeners := make([]*listener, 0, len(lcfgs))
+	var listenerAdvertise string
+	for _, lcfg := range lcfgs {
+		ln, raw, err := h.newListener(lcfg, h2s)
+		if err != nil {
+			_ = ts.Close()
+			if h3c != nil {
+				_ = h3c.Close()
+			}
+			for _, l := range listeners {
+				_ = l.ln.Close()
+			}
+			return err
+		}
+
+		if config.Logger.V(logger.InfoLevel) {
+			config.Logger.Info(config.Context, "Listening on "+ln.Addr().Network()+" "+ln.Addr().String())
+		}
+
+		if lcfg.Advertise && listenerAdvertise == "" {
+			listenerAdvertise = ln.Addr().String()
+		}
+
+		listeners = append(listeners, &listener{ln: ln, cfg: lcfg})
+		rawListeners = append(rawListeners, raw)
+	}
+
+	h.mu.Lock()
+	h.rawListeners = rawListeners
+	h.rawPacketConns = nil
+	if h3c != nil {
+		h.rawPacketConns = []net.PacketConn{h3c}
+	}
+	h.listenerAdvertise = listenerAdvertise
+	h.mu.Unlock()
+
+	closeListeners := func() {
+		_ = ts.Close()
+		if h3c != nil {
+			_ = h3c.Close()
+		}
+		for _, l := range listeners {
+			_ = l.ln.Close()
+		}
+	}
+
 	var handler http.Handler
 
 	// nolint: nestif
@@ -429,7 +736,7 @@ func (h *Server) Start() error {
 	switch {
 	case handler == nil && h.hd == nil:
 		handler = h
-	case len(h.handlers) > 0 && h.hd != nil:
+	case len(h.routeTable().handlers) > 0 && h.hd != nil:
 		handler = h
 	case handler == nil && h.hd != nil:
 		if hdlr, ok := h.hd.Handler().(http.Handler); ok {
@@ -438,134 +745,364 @@ func (h *Server) Start() error {
 	}
 
 	if handler == nil {
+		closeListeners()
 		return fmt.Errorf("cant process with nil handler")
 	}
 
 	if err := config.Broker.Connect(h.opts.Context); err != nil {
+		closeListeners()
 		return err
 	}
 
-	if err := config.RegisterCheck(h.opts.Context); err != nil {
-		if config.Logger.V(logger.ErrorLevel) {
-			config.Logger.Error(config.Context, fmt.Sprintf("Server %s-%s register check error", config.Name, config.ID), err)
-		}
-	} else {
-		if err = h.Register(); err != nil {
-			return err
-		}
-	}
-
 	fn := handler
 
 	var hs *http.Server
 	if h.opts.Context != nil {
-		if mwf, ok := h.opts.Context.Value(middlewareKey{}).([]func(http.Handler) http.Handler); ok && len(mwf) > 0 {
+		// server applies middlewares itself after route resolved
+		if mwf, ok := h.opts.Context.Value(middlewareKey{}).([]func(http.Handler) http.Handler); ok && len(mwf) > 0 && handler != http.Handler(h) {
 			// wrap the handler func
 			for i := len(mwf); i > 0; i-- {
 				fn = mwf[i-1](fn)
 			}
 		}
-		var ok bool
-		if hs, ok = h.opts.Context.Value(serverKey{}).(*http.Server); ok && hs != nil {
-			hs.Handler = fn
+		// shutdown http.Server can't be used again, so copy settings on each start
+		if uhs, ok := h.opts.Context.Value(serverKey{}).(*http.Server); ok && uhs != nil {
+			hs = newHTTPServer(uhs, fn)
 		} else {
 			hs = &http.Server{Handler: fn}
 		}
 	}
 
+	if h3s != nil {
+		h3s.Handler = hs.Handler
+		// advertise http3 endpoint to tcp clients
+		next := hs.Handler
+		hs.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = h3s.SetQUICHeaders(w.Header())
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	if h2s != nil {
+		// http2 server tracks connections of configured http.Server, so each one needs own copy
+		h2 := *h2s
+		if useH2C {
+			hs.Handler = h2c.NewHandler(hs.Handler, &h2)
+		}
+		// also registers graceful shutdown of http2 connections in hs.Shutdown
+		if err := http2.ConfigureServer(hs, &h2); err != nil {
+			closeListeners()
+			return err
+		}
+	}
+
+	for _, l := range listeners {
+		l.hs = h.newListenerServer(hs, l.cfg)
+		if h2s != nil {
+			h2 := *h2s
+			if err := http2.ConfigureServer(l.hs, &h2); err != nil {
+				closeListeners()
+				return err
+			}
+		}
+	}
+
+	h.stateLive.Store(1)
+	h.stateHealth.Store(1)
+
+	config.Meter.Gauge(ServerRegistered, func() float64 {
+		if h.Registered() {
+			return 1
+		}
+		return 0
+	}, "server", "http")
+
+	// first serve error stops server
+	fatal := make(chan error, 1)
+	serveError := func(err error) {
+		h.opts.Logger.Error(h.opts.Context, "serve error", err)
+		select {
+		case fatal <- err:
+		default:
+		}
+	}
+
 	go func() {
-		if cerr := hs.Serve(ts); cerr != nil && !errors.Is(cerr, http.ErrServerClosed) {
-			h.opts.Logger.Error(h.opts.Context, "serve error", cerr)
+		if cerr := hs.Serve(ts); cerr != nil && !errors.Is(cerr, http.ErrServerClosed) && !errors.Is(cerr, net.ErrClosed) {
+			serveError(cerr)
 		}
 		h.stateLive.Store(0)
 		h.stateReady.Store(0)
 		h.stateHealth.Store(0)
 	}()
 
-	go func() {
-		t := new(time.Ticker)
+	for _, l := range listeners {
+		go func(l *listener) {
+			if cerr := l.hs.Serve(l.ln); cerr != nil && !errors.Is(cerr, http.ErrServerClosed) && !errors.Is(cerr, net.ErrClosed) {
+				serveError(cerr)
+			}
+		}(l)
+	}
 
-		// only process if it exists
-		if config.RegisterInterval > time.Duration(0) {
-			// new ticker
-			t = time.NewTicker(config.RegisterInterval)
-		}
+	certsCtx, certsCancel := context.WithCancel(context.Background())
+	if certs != nil {
+		go certs.watch(certsCtx)
+	}
+
+	if h3s != nil {
+		go func() {
+			if cerr := h3s.Serve(h3c); cerr != nil && !errors.Is(cerr, http.ErrServerClosed) && !errors.Is(cerr, net.ErrClosed) {
+				serveError(fmt.Errorf("http3: %w", cerr))
+			}
+		}()
+	}
+
+	// closed after first registration of ready server
+	registered := make(chan struct{})
+	// set after AfterStart hooks completed, so BeforeStop hooks not run for aborted Start
+	var startedUp atomic.Bool
+
+	go func() {
+		rl := &registerLoop{h: h, config: config}
+
+		// register loop started after first registration
+		started := registered
+		var t *time.Timer
+		var tc <-chan time.Time
+		defer func() {
+			if t != nil {
+				t.Stop()
+			}
+		}()
 
 		// return error chan
 		var ch chan error
+		// fatal serve error
+		var ferr error
 
 	Loop:
 		for {
 			select {
-			// register self on interval
-			case <-t.C:
-				h.mu.RLock()
-				registered := h.registered
-				h.mu.RUnlock()
-				rerr := config.RegisterCheck(h.opts.Context)
-				// nolint: nestif
-				if rerr != nil && registered {
-					if config.Logger.V(logger.ErrorLevel) {
-						config.Logger.Error(config.Context, fmt.Sprintf("Server %s-%s register check error, deregister it", config.Name, config.ID), rerr)
-					}
-					// deregister self in case of error
-					if err := h.Deregister(); err != nil {
-						if config.Logger.V(logger.ErrorLevel) {
-							config.Logger.Error(config.Context, fmt.Sprintf("Server %s-%s deregister error", config.Name, config.ID), err)
-						}
-					}
-				} else if rerr != nil && !registered {
-					if config.Logger.V(logger.ErrorLevel) {
-						config.Logger.Error(config.Context, fmt.Sprintf("Server %s-%s register check error", config.Name, config.ID), rerr)
-					}
-					continue
-				}
-				if err := h.Register(); err != nil {
-					if config.Logger.V(logger.ErrorLevel) {
-						config.Logger.Error(config.Context, fmt.Sprintf("Server %s-%s register error", config.Name, config.ID), err)
-					}
-				}
-
-				if err := h.Register(); err != nil {
-					config.Logger.Error(config.Context, "Server register error", err)
+			case <-started:
+				started = nil
+				// only process if it exists
+				if config.RegisterInterval > time.Duration(0) {
+					t = time.NewTimer(jitter(config.RegisterInterval))
+					tc = t.C
 				}
+			// register self on interval
+			case <-tc:
+				t.Reset(rl.tick())
 			// wait for exit
 			case ch = <-h.exit:
 				break Loop
+			case ferr = <-fatal:
+				break Loop
+			}
+		}
+
+		certsCancel()
+
+		sd := &shutdown{config: config}
+
+		sd.enter(ShutdownPhaseNotReady)
+		h.stateReady.Store(0)
+
+		if startedUp.Load() {
+			if err := runLifecycleHooks(config.Context, beforeStopKey{}, true); err != nil {
+				config.Logger.Error(config.Context, "Server before stop hook error", err)
 			}
 		}
 
-		// deregister
-		if err := h.Deregister(); err != nil {
-			config.Logger.Error(config.Context, "Server deregister error", err)
+		if d, ok := config.Context.Value(preStopDelayKey{}).(time.Duration); ok && d > 0 {
+			sd.enter(ShutdownPhasePreStop)
+			time.Sleep(d)
+		}
+
+		sd.enter(ShutdownPhaseDeregister)
+		// may be already deregistered on failed health checks
+		if h.Registered() {
+			if err := h.Deregister(); err != nil {
+				config.Logger.Error(config.Context, "Server deregister error", err)
+			}
 		}
 
 		if err := config.Broker.Disconnect(config.Context); err != nil {
 			config.Logger.Error(config.Context, "Broker disconnect error", err)
 		}
 
+		sd.enter(ShutdownPhaseStopAccepting)
+		_ = ts.Close()
+		for _, l := range listeners {
+			_ = l.ln.Close()
+		}
+
+		sd.enter(ShutdownPhaseDrain)
 		ctx, cancel := context.WithTimeout(context.Background(), h.opts.GracefulTimeout)
 		defer cancel()
 
+		var wg sync.WaitGroup
+		var h3err error
+		if h3s != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h3err = h3s.Shutdown(ctx)
+			}()
+		}
+
+		lerrs := make([]error, len(listeners))
+		for i, l := range listeners {
+			wg.Add(1)
+			go func(i int, l *listener) {
+				defer wg.Done()
+				lerrs[i] = l.hs.Shutdown(ctx)
+			}(i, l)
+		}
+
 		err := hs.Shutdown(ctx)
-		if err != nil {
-			err = hs.Close()
+		wg.Wait()
+
+		if err != nil || h3err != nil || errors.Join(lerrs...) != nil {
+			sd.enter(ShutdownPhaseForceClose)
+			if err != nil {
+				err = hs.Close()
+			}
+			for i, l := range listeners {
+				if lerrs[i] != nil {
+					lerrs[i] = l.hs.Close()
+				}
+			}
+			if h3err != nil {
+				h3err = h3s.Close()
+			}
+		}
+		sd.finish()
+
+		// http3 server not closes passed conn
+		if h3c != nil {
+			if cerr := h3c.Close(); cerr != nil && h3err == nil {
+				h3err = cerr
+			}
+		}
+
+		if err == nil {
+			err = errors.Join(append(lerrs, h3err)...)
 		}
 
-		ch <- err
+		if herr := runLifecycleHooks(config.Context, afterStopKey{}, true); herr != nil {
+			config.Logger.Error(config.Context, "Server after stop hook error", herr)
+			err = errors.Join(err, herr)
+		}
+
+		h.stateLive.Store(0)
+		h.stateHealth.Store(0)
+		h.finish(done, ferr)
+
+		if ch != nil {
+			ch <- err
+		}
 	}()
 
-	h.stateLive.Store(1)
-	h.stateReady.Store(1)
-	h.stateHealth.Store(1)
+	stop := func() {
+		ch := make(chan error)
+		select {
+		case h.exit <- ch:
+			<-ch
+		case <-done:
+		}
+	}
+
+	// warmup before server marked ready
+	if err := runLifecycleHooks(config.Context, afterStartKey{}, false); err != nil {
+		stop()
+		return err
+	}
+	startedUp.Store(true)
+
+	// server may be already stopped by fatal serve error
+	select {
+	case <-done:
+		return nil
+	default:
+		h.stateReady.Store(1)
+	}
+
+	// register only ready and healthy server, so it not discoverable during warmup
+	if err := h.checkHealth(config); err != nil {
+		if config.Logger.V(logger.ErrorLevel) {
+			config.Logger.Error(config.Context, fmt.Sprintf("Server %s-%s health check error", config.Name, config.ID), err)
+		}
+	} else if err = h.Register(); err != nil {
+		stop()
+		return err
+	}
+	close(registered)
 
 	return nil
 }
 
+// finish marks server stopped with fatal error
+func (h *Server) finish(done chan struct{}, err error) {
+	h.mu.Lock()
+	if err != nil {
+		h.err = err
+	}
+	h.state.Store(uint32(StateStopped))
+	select {
+	case <-done:
+	default:
+		close(done)
+	}
+	h.mu.Unlock()
+}
+
+// Stop gracefully stops server, it does nothing if server not running
 func (h *Server) Stop() error {
+	h.lmu.Lock()
+	defer h.lmu.Unlock()
+
+	if !h.state.CompareAndSwap(uint32(StateRunning), uint32(StateStopping)) {
+		return nil
+	}
+
+	h.mu.RLock()
+	done := h.done
+	h.mu.RUnlock()
+
 	ch := make(chan error)
-	h.exit <- ch
-	return <-ch
+	select {
+	case h.exit <- ch:
+		return <-ch
+	case <-done:
+		// already stopped by fatal serve error
+		return nil
+	}
+}
+
+// State returns server lifecycle state
+func (h *Server) State() State {
+	return State(h.state.Load())
+}
+
+// Done returns channel closed when server stopped
+func (h *Server) Done() <-chan struct{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.done
+}
+
+// Wait blocks till server stopped and returns fatal serve error if any
+func (h *Server) Wait() error {
+	<-h.Done()
+	return h.Err()
+}
+
+// Err returns fatal serve error that stopped server
+func (h *Server) Err() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.err
 }
 
 func (h *Server) String() string {
@@ -594,13 +1131,15 @@ func NewServer(opts ...server.Option) *Server {
 	if v, ok := options.Context.Value(errorHandlerKey{}).(errorHandler); ok && v != nil {
 		eh = v
 	}
-	return &Server{
+	srv := &Server{
 		stateLive:    &atomic.Uint32{},
 		stateReady:   &atomic.Uint32{},
 		stateHealth:  &atomic.Uint32{},
 		opts:         options,
 		exit:         make(chan chan error),
+		done:         make(chan struct{}),
 		errorHandler: eh,
-		pathHandlers: rhttp.NewTrie(),
 	}
+	srv.routes.Store(newRouteTable())
+	return srv
 }
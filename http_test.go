@@ -0,0 +1,318 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/codec"
+	"go.unistack.org/micro/v4/server"
+	"golang.org/x/net/http2"
+)
+
+// newTestCertificate generates self-signed certificate for hosts
+func newTestCertificate(t *testing.T, hosts ...string) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: hosts[0]},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		} else if u, err := url.Parse(host); err == nil && u.Scheme != "" {
+			tpl.URIs = append(tpl.URIs, u)
+		} else if strings.Contains(host, "@") {
+			tpl.EmailAddresses = append(tpl.EmailAddresses, host)
+		} else {
+			tpl.DNSNames = append(tpl.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: crt}, crt
+}
+
+func TestServerH2C(t *testing.T) {
+	srv := NewServer(
+		server.Address("127.0.0.1:0"),
+		H2C(true),
+		HTTP2Server(&http2.Server{MaxConcurrentStreams: 10}),
+		PathHandler(http.MethodGet, "/proto", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Proto))
+		}),
+	)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+	defer func() {
+		require.NoError(t, srv.Stop())
+	}()
+
+	// prior knowledge
+	c := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	rsp, err := c.Get("http://" + srv.Options().Address + "/proto")
+	require.NoError(t, err)
+	buf, err := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/2.0", string(buf))
+
+	// plain http/1.1 still served
+	rsp, err = http.Get("http://" + srv.Options().Address + "/proto")
+	require.NoError(t, err)
+	buf, err = io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/1.1", string(buf))
+}
+
+func TestServerHTTP3(t *testing.T) {
+	crt, leaf := newTestCertificate(t, "127.0.0.1")
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	srv := NewServer(
+		server.Address("127.0.0.1:0"),
+		server.TLSConfig(&tls.Config{Certificates: []tls.Certificate{crt}}),
+		HTTP3Server(&http3.Server{}),
+		PathHandler(http.MethodGet, "/proto", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Proto))
+		}),
+	)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+	defer func() {
+		require.NoError(t, srv.Stop())
+	}()
+
+	// tcp response advertise http3
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	rsp, err := c.Get("https://" + srv.Options().Address + "/proto")
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, rsp.Body)
+	rsp.Body.Close()
+	require.Contains(t, rsp.Header.Get("Alt-Svc"), "h3=")
+
+	rt := &http3.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	defer rt.Close()
+	c = &http.Client{Transport: rt}
+	rsp, err = c.Get("https://" + srv.http3Address + "/proto")
+	require.NoError(t, err)
+	buf, err := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/3.0", string(buf))
+
+	svc, err := srv.newRegisterService()
+	require.NoError(t, err)
+	require.Equal(t, []string{srv.http3Address}, svc.Nodes[0].Metadata.Get("http3"))
+}
+
+func TestServerListeners(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "srv.sock")
+
+	srv := NewServer(
+		server.Address("127.0.0.1:0"),
+		Listen(ListenerConfig{Network: "unix", Address: sock, Filter: PathPrefixFilter("/internal")}),
+		Listen(ListenerConfig{Address: "127.0.0.1:0", Advertise: true}),
+		PathHandler(http.MethodGet, "/public", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("public"))
+		}),
+		PathHandler(http.MethodGet, "/internal", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("internal"))
+		}),
+	)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+
+	uc := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+
+	get := func(c *http.Client, url string) (int, string) {
+		rsp, err := c.Get(url)
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		buf, err := io.ReadAll(rsp.Body)
+		require.NoError(t, err)
+		return rsp.StatusCode, string(buf)
+	}
+
+	code, body := get(uc, "http://unix/internal")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "internal", body)
+
+	code, _ = get(uc, "http://unix/public")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, body = get(http.DefaultClient, "http://"+srv.Options().Address+"/public")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "public", body)
+
+	svc, err := srv.newRegisterService()
+	require.NoError(t, err)
+	advt := svc.Nodes[0].Address
+	require.NotEqual(t, srv.Options().Address, advt)
+	code, body = get(http.DefaultClient, "http://"+advt+"/internal")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "internal", body)
+	require.Empty(t, srv.Options().Advertise)
+
+	require.NoError(t, srv.Stop())
+
+	// restarted server advertises new listener address
+	require.NoError(t, srv.Start())
+	svc, err = srv.newRegisterService()
+	require.NoError(t, err)
+	require.Equal(t, srv.listenerAdvertise, svc.Nodes[0].Address)
+	code, body = get(http.DefaultClient, "http://"+svc.Nodes[0].Address+"/internal")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "internal", body)
+
+	require.NoError(t, srv.Stop())
+
+	_, err = os.Stat(sock)
+	require.True(t, os.IsNotExist(err))
+}
+
+// testJSONCodec used by endpoint tests, decodes empty body as empty request
+type testJSONCodec struct{}
+
+func (testJSONCodec) Marshal(v interface{}, _ ...codec.Option) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (testJSONCodec) Unmarshal(buf []byte, v interface{}, _ ...codec.Option) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	return json.Unmarshal(buf, v)
+}
+
+func (testJSONCodec) String() string {
+	return "json"
+}
+
+// newTestServer returns initialized server listening on random port with json codec
+func newTestServer(t *testing.T, opts ...server.Option) *Server {
+	srv := NewServer(append([]server.Option{
+		server.Address("127.0.0.1:0"),
+		server.Codec("application/json", testJSONCodec{}),
+	}, opts...)...)
+	require.NoError(t, srv.Init())
+	return srv
+}
+
+// handleTestEndpoints registers handler endpoints on test server
+func handleTestEndpoints(t *testing.T, srv *Server, hdlr interface{}, eps []EndpointMetadata, opts ...server.HandlerOption) {
+	require.NoError(t, srv.Handle(srv.NewHandler(hdlr, append(opts, HandlerEndpoints(eps))...)))
+}
+
+// EchoRequest is request of test endpoints
+type EchoRequest struct {
+	Name string `json:"name"`
+}
+
+// EchoResponse is response of test endpoints
+type EchoResponse struct {
+	Name string `json:"name"`
+}
+
+// EchoHandler returns request name
+type EchoHandler struct{}
+
+func (*EchoHandler) Get(_ context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	rsp.Name = req.Name
+	return nil
+}
+
+func (*EchoHandler) Update(_ context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	rsp.Name = req.Name
+	return nil
+}
+
+// testClient sends requests to started test server
+type testClient struct {
+	*http.Client
+	t   *testing.T
+	srv *Server
+}
+
+// startTestServer starts server, on test cleanup unused client connections closed, as they delay shutdown,
+// and server stopped
+func startTestServer(t *testing.T, srv *Server) *testClient {
+	require.NoError(t, srv.Start())
+	c := &testClient{Client: &http.Client{Transport: &http.Transport{}}, t: t, srv: srv}
+	t.Cleanup(func() {
+		c.CloseIdleConnections()
+		require.NoError(t, srv.Stop())
+	})
+	return c
+}
+
+// url returns url of server path
+func (c *testClient) url(path string) string {
+	return "http://" + c.srv.Options().Address + path
+}
+
+// request returns request to server path
+func (c *testClient) request(method, path string, body io.Reader) *http.Request {
+	req, err := http.NewRequest(method, c.url(path), body)
+	require.NoError(c.t, err)
+	return req
+}
+
+// do sends request and returns response with read body
+func (c *testClient) do(req *http.Request) (*http.Response, string) {
+	rsp, err := c.Do(req)
+	require.NoError(c.t, err)
+	defer rsp.Body.Close()
+	buf, err := io.ReadAll(rsp.Body)
+	require.NoError(c.t, err)
+	return rsp, string(buf)
+}
+
+// get sends GET request and returns status code and body
+func (c *testClient) get(path string) (int, string) {
+	rsp, body := c.do(c.request(http.MethodGet, path, nil))
+	return rsp.StatusCode, body
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+	"errors"
+)
+
+// State is a server lifecycle state
+type State uint32
+
+const (
+	// StateCreated is initial state of server
+	StateCreated State = iota
+	// StateStarting means server creates listeners and registers itself
+	StateStarting
+	// StateRunning means server serves requests
+	StateRunning
+	// StateStopping means server drains requests
+	StateStopping
+	// StateStopped means server stopped by Stop or by fatal serve error, it can be started again
+	StateStopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateCreated:
+		return "created"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateStopping:
+		return "stopping"
+	case StateStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// LifecycleHook is a callback that runs in Start and Stop flow
+type LifecycleHook func(ctx context.Context) error
+
+// runLifecycleHooks runs hooks stored in context by key, first error stops Start hooks, Stop hooks all run
+func runLifecycleHooks(ctx context.Context, key interface{}, all bool) error {
+	hooks, _ := ctx.Value(key).([]LifecycleHook)
+
+	var errs []error
+	for _, fn := range hooks {
+		if err := fn(ctx); err != nil {
+			if !all {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/server"
+)
+
+// failListener returns error from Accept after fail closed
+type failListener struct {
+	net.Listener
+	fail chan struct{}
+}
+
+func (l *failListener) Accept() (net.Conn, error) {
+	<-l.fail
+	return nil, errors.New("accept failed")
+}
+
+func TestServerStopNotStarted(t *testing.T) {
+	srv := NewServer(server.Address("127.0.0.1:0"))
+	require.NoError(t, srv.Init())
+	require.Equal(t, StateCreated, srv.State())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Stop()
+	}()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("stop blocked")
+	}
+}
+
+func TestServerRestart(t *testing.T) {
+	srv := NewServer(
+		server.Address("127.0.0.1:0"),
+		PathHandler(http.MethodGet, "/ping", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("pong"))
+		}),
+	)
+	require.NoError(t, srv.Init())
+
+	// unused client connections delay shutdown
+	c := &http.Client{Transport: &http.Transport{}}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, srv.Start())
+		require.NoError(t, srv.Start())
+		require.Equal(t, StateRunning, srv.State())
+
+		rsp, err := c.Get("http://" + srv.Options().Address + "/ping")
+		require.NoError(t, err)
+		rsp.Body.Close()
+		require.Equal(t, http.StatusOK, rsp.StatusCode)
+		c.CloseIdleConnections()
+
+		done := srv.Done()
+		require.NoError(t, srv.Stop())
+		require.NoError(t, srv.Stop())
+		require.Equal(t, StateStopped, srv.State())
+		<-done
+		require.NoError(t, srv.Wait())
+	}
+}
+
+func TestServerConcurrentStartStop(t *testing.T) {
+	srv := NewServer(server.Address("127.0.0.1:0"))
+	require.NoError(t, srv.Init())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = srv.Start()
+			_ = srv.State()
+		}()
+		go func() {
+			defer wg.Done()
+			_ = srv.Stop()
+			_ = srv.Err()
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, srv.Stop())
+	require.Equal(t, StateStopped, srv.State())
+}
+
+func TestServerServeError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	fl := &failListener{Listener: ln, fail: make(chan struct{})}
+
+	srv := NewServer(server.Listener(fl))
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+	require.Equal(t, StateRunning, srv.State())
+
+	close(fl.fail)
+	require.EqualError(t, srv.Wait(), "accept failed")
+	require.Equal(t, StateStopped, srv.State())
+	require.False(t, srv.Ready())
+	require.NoError(t, srv.Stop())
+}
+
+func TestServerLifecycleHooks(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	hook := func(name string, err error) LifecycleHook {
+		return func(context.Context) error {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			return err
+		}
+	}
+
+	var srv *Server
+	srv = NewServer(
+		server.Address("127.0.0.1:0"),
+		BeforeStart(hook("before_start", nil)),
+		AfterStart(func(ctx context.Context) error {
+			// warmup runs while server not ready yet
+			require.False(t, srv.Ready())
+			return hook("after_start", nil)(ctx)
+		}),
+		BeforeStop(hook("before_stop", nil)),
+		AfterStop(hook("after_stop", errors.New("close pool"))),
+	)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+	require.True(t, srv.Ready())
+	require.EqualError(t, srv.Stop(), "close pool")
+
+	require.Equal(t, []string{"before_start", "after_start", "before_stop", "after_stop"}, calls)
+}
+
+func TestServerLifecycleHooksAbortStart(t *testing.T) {
+	srv := NewServer(
+		server.Address("127.0.0.1:0"),
+		BeforeStart(func(context.Context) error { return errors.New("before") }),
+	)
+	require.NoError(t, srv.Init())
+	require.EqualError(t, srv.Start(), "before")
+	require.Equal(t, StateStopped, srv.State())
+
+	var stopped, beforeStop bool
+	srv = NewServer(
+		server.Address("127.0.0.1:0"),
+		AfterStart(func(context.Context) error { return errors.New("warmup") }),
+		BeforeStop(func(context.Context) error { beforeStop = true; return nil }),
+		AfterStop(func(context.Context) error { stopped = true; return nil }),
+	)
+	require.NoError(t, srv.Init())
+	require.EqualError(t, srv.Start(), "warmup")
+	require.Equal(t, StateStopped, srv.State())
+	require.False(t, srv.Ready())
+	require.True(t, stopped)
+	// server never became ready, so nothing to prepare before stop
+	require.False(t, beforeStop)
+	require.EqualError(t, srv.Wait(), "warmup")
+
+	// listener closed
+	_, err := net.Dial("tcp", srv.Options().Address)
+	require.Error(t, err)
+}
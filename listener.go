@@ -0,0 +1,116 @@
+package http
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/netutil"
+)
+
+// ListenerConfig holds settings of additional server listener
+type ListenerConfig struct {
+	// Listener used as is instead of creating new one from Network and Address
+	Listener net.Listener
+	// TLSConfig enables tls on listener
+	TLSConfig *tls.Config
+	// Filter decides which requests served via listener, others got 404, all requests served if nil
+	Filter func(*http.Request) bool
+	// Network is tcp, tcp4, tcp6 or unix, tcp if empty
+	Network string
+	// Address to listen on, for unix network it is socket path
+	Address string
+	// MaxConn limits simultaneous connections on listener
+	MaxConn int
+	// Advertise marks listener address to be used in register if server Advertise not set
+	Advertise bool
+}
+
+// PathPrefixFilter returns listener filter that allows only requests with specified path prefixes
+func PathPrefixFilter(prefixes ...string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type listener struct {
+	ln  net.Listener
+	hs  *http.Server
+	cfg ListenerConfig
+}
+
+// newListener creates listener from config, tls listener advertise h2 if http2 server configured.
+// Returns raw listener without tls and limits too.
+func (h *Server) newListener(cfg ListenerConfig, h2s *http2.Server) (net.Listener, net.Listener, error) {
+	ln := cfg.Listener
+	if ln == nil {
+		network := cfg.Network
+		if network == "" {
+			network = "tcp"
+		}
+
+		var err error
+		if ln, err = h.listen(network, cfg.Address); err != nil {
+			return nil, nil, err
+		}
+	}
+	raw := ln
+
+	if tc := cfg.TLSConfig; tc != nil {
+		if h2s != nil && !slices.Contains(tc.NextProtos, http2.NextProtoTLS) {
+			tc = tc.Clone()
+			tc.NextProtos = append([]string{http2.NextProtoTLS, "http/1.1"}, tc.NextProtos...)
+		}
+		ln = tls.NewListener(ln, tc)
+	}
+
+	if cfg.MaxConn > 0 {
+		ln = netutil.LimitListener(ln, cfg.MaxConn)
+	}
+	ln = &onceCloseListener{Listener: ln}
+
+	return ln, raw, nil
+}
+
+// newListenerServer creates http.Server for additional listener with the same settings as main server
+func (h *Server) newListenerServer(hs *http.Server, cfg ListenerConfig) *http.Server {
+	handler := hs.Handler
+	if cfg.Filter != nil {
+		next := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cfg.Filter(r) {
+				h.errorHandler(r.Context(), nil, w, r, fmt.Errorf("not matching route found"), http.StatusNotFound)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	return newHTTPServer(hs, handler)
+}
+
+// newHTTPServer creates http.Server with handler and settings copied from hs
+func newHTTPServer(hs *http.Server, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:                      handler,
+		DisableGeneralOptionsHandler: hs.DisableGeneralOptionsHandler,
+		ReadTimeout:                  hs.ReadTimeout,
+		ReadHeaderTimeout:            hs.ReadHeaderTimeout,
+		WriteTimeout:                 hs.WriteTimeout,
+		IdleTimeout:                  hs.IdleTimeout,
+		MaxHeaderBytes:               hs.MaxHeaderBytes,
+		ConnState:                    hs.ConnState,
+		ErrorLog:                     hs.ErrorLog,
+		BaseContext:                  hs.BaseContext,
+		ConnContext:                  hs.ConnContext,
+	}
+}
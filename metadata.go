@@ -2,6 +2,8 @@ package http
 
 import (
 	"context"
+	"net/http"
+	"sync"
 
 	"go.unistack.org/micro/v4/metadata"
 )
@@ -9,7 +11,9 @@ import (
 type (
 	rspMetadataKey struct{}
 	rspMetadataVal struct {
-		m metadata.Metadata
+		m  metadata.Metadata
+		t  metadata.Metadata
+		mu sync.Mutex
 	}
 )
 
@@ -17,7 +21,7 @@ type (
 // It expects the context to contain a *rspMetadataVal value under the rspMetadataKey{} key.
 // If the value is missing or invalid, the function does nothing.
 //
-// Note: this function is not thread-safe. Synchronization is required if used from multiple goroutines.
+// It is safe to call from multiple goroutines.
 func AppendResponseMetadata(ctx context.Context, md metadata.Metadata) {
 	if md == nil {
 		return
@@ -28,20 +32,88 @@ func AppendResponseMetadata(ctx context.Context, md metadata.Metadata) {
 		return
 	}
 
+	val.mu.Lock()
 	for key, values := range md {
 		val.m.Append(key, values...)
 	}
+	val.mu.Unlock()
 }
 
-// getResponseMetadata retrieves the metadata.Metadata stored in the context.
+// SetResponseCookie adds Set-Cookie header to response, cookie validated before adding.
 //
-// Note: this function is not thread-safe. Synchronization is required if used from multiple goroutines.
-// If you plan to modify the returned metadata, make a full copy to avoid affecting shared state.
+// It is safe to call from multiple goroutines.
+func SetResponseCookie(ctx context.Context, c *http.Cookie) error {
+	if err := c.Valid(); err != nil {
+		return err
+	}
+	AppendResponseMetadata(ctx, metadata.Metadata{"Set-Cookie": []string{c.String()}})
+	return nil
+}
+
+// SetResponseTrailer sets http trailer written after response body, it can be called
+// till endpoint handler returns. If handler writes response itself, trailers set after
+// response header written are sent only if body flushed before handler returns.
+//
+// It is safe to call from multiple goroutines.
+func SetResponseTrailer(ctx context.Context, key string, values ...string) {
+	val, ok := ctx.Value(rspMetadataKey{}).(*rspMetadataVal)
+	if !ok || val == nil {
+		return
+	}
+
+	val.mu.Lock()
+	if val.t == nil {
+		val.t = metadata.New(1)
+	}
+	val.t[http.CanonicalHeaderKey(key)] = values
+	val.mu.Unlock()
+}
+
+// getResponseMetadata retrieves copy of the metadata.Metadata stored in the context.
 func getResponseMetadata(ctx context.Context) metadata.Metadata {
 	val, ok := ctx.Value(rspMetadataKey{}).(*rspMetadataVal)
 	if !ok || val == nil || val.m == nil {
 		return nil
 	}
 
-	return val.m
+	val.mu.Lock()
+	defer val.mu.Unlock()
+
+	return metadata.Copy(val.m)
+}
+
+// writeHeader copies response metadata to header, it used when endpoint handler writes response itself
+func (val *rspMetadataVal) writeHeader(h http.Header) {
+	val.mu.Lock()
+	defer val.mu.Unlock()
+
+	for k, v := range val.m {
+		h[k] = append([]string(nil), v...)
+	}
+}
+
+// declareTrailers adds Trailer header with trailers known before response header written,
+// so http server uses chunked encoding for http/1.1 responses
+func (val *rspMetadataVal) declareTrailers(h http.Header) {
+	val.mu.Lock()
+	defer val.mu.Unlock()
+
+	for k := range val.t {
+		h.Add("Trailer", k)
+	}
+}
+
+// writeResponseTrailers writes trailers stored in the context, must be called after response body written
+func writeResponseTrailers(ctx context.Context, w http.ResponseWriter) {
+	val, ok := ctx.Value(rspMetadataKey{}).(*rspMetadataVal)
+	if !ok || val == nil {
+		return
+	}
+
+	val.mu.Lock()
+	defer val.mu.Unlock()
+
+	for k, v := range val.t {
+		w.Header()[http.TrailerPrefix+k] = v
+	}
 }
@@ -2,6 +2,8 @@ package http
 
 import (
 	"context"
+	"net/http"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -134,3 +136,56 @@ func TestGetResponseMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendResponseMetadataConcurrent(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rspMetadataKey{}, &rspMetadataVal{m: metadata.New(0)})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			AppendResponseMetadata(ctx, metadata.Pairs("key", "val"))
+			SetResponseTrailer(ctx, "checksum", "val")
+			_ = getResponseMetadata(ctx)
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, getResponseMetadata(ctx)["key"], 10)
+}
+
+func TestSetResponseCookie(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rspMetadataKey{}, &rspMetadataVal{m: metadata.New(0)})
+
+	require.Error(t, SetResponseCookie(ctx, &http.Cookie{Name: "bad name", Value: "v"}))
+	require.NoError(t, SetResponseCookie(ctx, &http.Cookie{
+		Name: "session", Value: "abc", Path: "/", HttpOnly: true, Secure: true, SameSite: http.SameSiteStrictMode, MaxAge: 60,
+	}))
+	require.Equal(t, []string{"session=abc; Path=/; Max-Age=60; HttpOnly; Secure; SameSite=Strict"}, getResponseMetadata(ctx)["Set-Cookie"])
+}
+
+type TrailerTestHandler struct{}
+
+func (*TrailerTestHandler) Get(ctx context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	if err := SetResponseCookie(ctx, &http.Cookie{Name: "session", Value: req.Name}); err != nil {
+		return err
+	}
+	rsp.Name = req.Name
+	SetResponseTrailer(ctx, "X-Checksum", "sum")
+	return nil
+}
+
+func TestServerResponseTrailers(t *testing.T) {
+	srv := newTestServer(t)
+	require.NoError(t, srv.Handle(srv.NewHandler(&TrailerTestHandler{}, HandlerEndpoints([]EndpointMetadata{
+		{Name: "TrailerTest.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+	}))))
+	c := startTestServer(t, srv)
+
+	// trailers available after body read
+	rsp, _ := c.do(c.request(http.MethodGet, "/v1/abc", nil))
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.Equal(t, "session=abc", rsp.Header.Get("Set-Cookie"))
+	require.Equal(t, "sum", rsp.Trailer.Get("X-Checksum"))
+}
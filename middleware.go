@@ -0,0 +1,66 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"go.unistack.org/micro/v4/server"
+)
+
+// RouteInfo describes route matched by request
+type RouteInfo struct {
+	// Options contains options of handler that serves route, empty for path handlers
+	Options server.HandlerOptions
+	Route
+}
+
+type routeInfoKey struct{}
+
+// RouteInfoFromContext returns route matched by request, it available for
+// handler and route middlewares, path handlers and endpoints
+func RouteInfoFromContext(ctx context.Context) (*RouteInfo, bool) {
+	ri, ok := ctx.Value(routeInfoKey{}).(*RouteInfo)
+	return ri, ok
+}
+
+func newRouteInfoContext(ctx context.Context, ri *RouteInfo) context.Context {
+	return context.WithValue(ctx, routeInfoKey{}, ri)
+}
+
+// pathRoute holds path handler with its route in path handlers trie
+type pathRoute struct {
+	handler http.HandlerFunc
+	route   Route
+}
+
+// chain wraps handler with middlewares, first middleware is outermost
+func chain(mws []func(http.Handler) http.Handler, handler http.Handler) http.Handler {
+	for i := len(mws); i > 0; i-- {
+		handler = mws[i-1](handler)
+	}
+	return handler
+}
+
+// middlewares returns handler middlewares followed by endpoint ones
+func (h *httpHandler) middlewares(endpoint string) []func(http.Handler) http.Handler {
+	mws, _ := h.opts.Context.Value(handlerMiddlewareKey{}).([]func(http.Handler) http.Handler)
+	if emws, ok := h.opts.Context.Value(endpointMiddlewareKey{}).(map[string][]func(http.Handler) http.Handler); ok {
+		mws = append(mws[:len(mws):len(mws)], emws[endpoint]...)
+	}
+	return mws
+}
+
+// routeInfo returns info about route served by handler
+func (h *httpHandler) routeInfo(p *patHandler, method, host string) *RouteInfo {
+	ri := &RouteInfo{Route: p.route, Options: h.opts}
+	ri.Method = method
+	ri.Host, _ = matchHost(h.hosts, host)
+	return ri
+}
+
+// withRoute returns copy of endpoint handler for route
+func (p *patHandler) withRoute(r Route) *patHandler {
+	np := *p
+	np.route = r
+	return &np
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func routeHeaderMiddleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ri, ok := RouteInfoFromContext(r.Context()); ok {
+				w.Header().Add("X-Route", name+" "+ri.Method+" "+ri.Path+" "+ri.Name)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestServerRouteMiddleware(t *testing.T) {
+	srv := newTestServer(t,
+		Middleware(routeHeaderMiddleware("server")),
+		PathMiddleware(http.MethodGet, "/items/{id}", routeHeaderMiddleware("path")),
+		PathHandler(http.MethodGet, "/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+			ri, ok := RouteInfoFromContext(r.Context())
+			require.True(t, ok)
+			_, _ = w.Write([]byte(ri.Method + " " + ri.Path))
+		}),
+	)
+	handleTestEndpoints(t, srv, &EchoHandler{}, []EndpointMetadata{
+		{Name: "Echo.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+		{Name: "Echo.Update", Method: http.MethodPut, Path: "/v1/{name}", Body: "*"},
+	}, HandlerMiddleware(routeHeaderMiddleware("handler")), EndpointMiddleware("Echo.Update", routeHeaderMiddleware("endpoint")))
+	c := startTestServer(t, srv)
+
+	do := func(method, path string) (*http.Response, string) {
+		return c.do(c.request(method, path, nil))
+	}
+
+	rsp, _ := do(http.MethodGet, "/v1/name")
+	require.Equal(t, []string{
+		"server GET /v1/{name} Echo.Get",
+		"handler GET /v1/{name} Echo.Get",
+	}, rsp.Header.Values("X-Route"))
+
+	rsp, _ = do(http.MethodPut, "/v1/name")
+	require.Equal(t, []string{
+		"server PUT /v1/{name} Echo.Update",
+		"handler PUT /v1/{name} Echo.Update",
+		"endpoint PUT /v1/{name} Echo.Update",
+	}, rsp.Header.Values("X-Route"))
+
+	rsp, body := do(http.MethodGet, "/items/1")
+	require.Equal(t, []string{
+		"server GET /items/{id}",
+		"path GET /items/{id}",
+	}, rsp.Header.Values("X-Route"))
+	require.Equal(t, "GET /items/{id}", body)
+
+	rsp, _ = do(http.MethodGet, "/unknown")
+	require.Equal(t, http.StatusNotFound, rsp.StatusCode)
+	require.Empty(t, rsp.Header.Values("X-Route"))
+
+	for _, r := range srv.Routes() {
+		switch r.Name {
+		case "Echo.Get":
+			require.Len(t, r.Middlewares, 2)
+		case "Echo.Update":
+			require.Len(t, r.Middlewares, 3)
+		default:
+			require.Len(t, r.Middlewares, 2)
+		}
+	}
+
+	// endpoints served outside of server with handler and endpoint middlewares only
+	fn, err := srv.HTTPHandlerFunc((&EchoHandler{}).Update)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	fn(w, httptest.NewRequest(http.MethodPut, "/v1/name", nil))
+	require.Equal(t, []string{
+		"handler PUT /v1/{name} Echo.Update",
+		"endpoint PUT /v1/{name} Echo.Update",
+	}, w.Header().Values("X-Route"))
+
+	w = httptest.NewRecorder()
+	fn(w, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServerMiddlewareRewrite(t *testing.T) {
+	srv := newTestServer(t,
+		Middleware(func(next http.Handler) http.Handler { return http.StripPrefix("/prefix", next) }),
+	)
+	handleTestEndpoints(t, srv, &EchoHandler{}, []EndpointMetadata{
+		{Name: "Echo.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+	}, HandlerMiddleware(routeHeaderMiddleware("handler")))
+	c := startTestServer(t, srv)
+
+	// route resolved again for path stripped by server middleware
+	rsp, body := c.do(c.request(http.MethodGet, "/prefix/v1/name", nil))
+	require.Equal(t, http.StatusOK, rsp.StatusCode, body)
+	require.JSONEq(t, `{"name":"name"}`, body)
+	require.Equal(t, []string{"handler GET /v1/{name} Echo.Get"}, rsp.Header.Values("X-Route"))
+
+	code, _ := c.get("/prefix/v2/name")
+	require.Equal(t, http.StatusNotFound, code)
+}
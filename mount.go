@@ -0,0 +1,78 @@
+package http
+
+import (
+	"net"
+	"strings"
+)
+
+// normalizeBasePath returns base path with leading slash and without trailing one, empty for root
+func normalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// stripBasePath returns path relative to base path, false if path not under base path
+func stripBasePath(base, p string) (string, bool) {
+	if base == "" {
+		return p, true
+	}
+	if !strings.HasPrefix(p, base) {
+		return "", false
+	}
+	p = p[len(base):]
+	switch {
+	case p == "":
+		return "/", true
+	case p[0] != '/':
+		return "", false
+	}
+	return p, true
+}
+
+// requestHost returns lowercased host without port and trailing dot
+func requestHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// hostRank returns how specific patterns match host, -1 if no one matches,
+// 0 if patterns empty, longer wildcard patterns ranked higher and exact match ranked highest
+func hostRank(patterns []string, host string) int {
+	_, rank := matchHost(patterns, host)
+	return rank
+}
+
+// patternRank returns rank of host matched by pattern, see hostRank
+func patternRank(pattern string) int {
+	switch lpattern := strings.ToLower(pattern); {
+	case lpattern == "":
+		return 0
+	case strings.HasPrefix(lpattern, "*."):
+		return len(lpattern)
+	}
+	return 1 << 16
+}
+
+// matchHost returns most specific pattern that matches host and its rank
+func matchHost(patterns []string, host string) (string, int) {
+	if len(patterns) == 0 {
+		return "", 0
+	}
+	var match string
+	rank := -1
+	for _, pattern := range patterns {
+		lpattern := strings.ToLower(pattern)
+		switch {
+		case lpattern == host:
+			return pattern, 1 << 16
+		case strings.HasPrefix(lpattern, "*.") && strings.HasSuffix(host, lpattern[1:]) && len(lpattern) > rank:
+			match, rank = pattern, len(lpattern)
+		}
+	}
+	return match, rank
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/register"
+	"go.unistack.org/micro/v4/server"
+	rhttp "go.unistack.org/micro/v4/util/http"
+)
+
+func TestStripBasePath(t *testing.T) {
+	for _, tc := range []struct {
+		base, path, out string
+		ok              bool
+	}{
+		{"", "/items", "/items", true},
+		{"/api/v1", "/api/v1/items", "/items", true},
+		{"/api/v1", "/api/v1", "/", true},
+		{"/api/v1", "/api/v1x", "", false},
+		{"/api/v1", "/items", "", false},
+	} {
+		out, ok := stripBasePath(tc.base, tc.path)
+		require.Equal(t, tc.ok, ok, tc.path)
+		require.Equal(t, tc.out, out, tc.path)
+	}
+	require.Equal(t, "/api/v1", normalizeBasePath("api/v1/"))
+	require.Equal(t, "", normalizeBasePath("/"))
+}
+
+func TestHostRank(t *testing.T) {
+	require.Equal(t, 0, hostRank(nil, "example.com"))
+	require.Equal(t, -1, hostRank([]string{"example.com"}, "other.com"))
+	require.Equal(t, -1, hostRank([]string{"*.example.com"}, "example.com"))
+	require.Greater(t, hostRank([]string{"*.example.com"}, "a.b.example.com"), 0)
+	require.Greater(t, hostRank([]string{"*.b.example.com"}, "a.b.example.com"), hostRank([]string{"*.example.com"}, "a.b.example.com"))
+	require.Greater(t, hostRank([]string{"*.example.com", "a.example.com"}, "a.example.com"), hostRank([]string{"*.example.com"}, "a.example.com"))
+	require.Equal(t, "example.com", requestHost("Example.COM.:8080"))
+}
+
+func TestRouteTableHostHandlers(t *testing.T) {
+	rt := newRouteTable()
+	for name, hosts := range map[string][]string{
+		"any":      nil,
+		"wildcard": {"*.example.com"},
+		"exact":    {"A.example.com", "*.b.example.com"},
+	} {
+		trie := rhttp.NewTrie()
+		require.NoError(t, trie.Insert([]string{http.MethodGet}, "/items", &patHandler{name: name}))
+		rt.handlers[name] = &httpHandler{name: name, hosts: hosts, handlers: trie}
+	}
+	require.NoError(t, rt.rebuild())
+
+	for host, name := range map[string]string{
+		"a.example.com":   "exact",
+		"x.b.example.com": "exact",
+		"c.example.com":   "wildcard",
+		"other.com":       "any",
+	} {
+		hh, _, _, err := rt.searchHandlers(host, http.MethodGet, "/items")
+		require.NoError(t, err)
+		require.Equal(t, name, hh.name, host)
+	}
+
+	_, _, _, err := rt.searchHandlers("other.com", http.MethodPost, "/items")
+	require.ErrorIs(t, err, rhttp.ErrMethodNotAllowed)
+}
+
+func TestServerBasePathHosts(t *testing.T) {
+	text := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(s + " " + r.URL.Path))
+		}
+	}
+	reg := register.NewRegister()
+	srv := newTestServer(t,
+		server.Name("test"),
+		server.Register(reg),
+		BasePath("/api/v1/"),
+		PathHandler(http.MethodGet, "/items", text("any")),
+		HostPathHandler("*.example.com", http.MethodGet, "/items", text("wildcard")),
+		HostPathHandler("Admin.example.com", http.MethodGet, "/items", text("admin")),
+		HostPathHandler("admin.example.com", http.MethodGet, "/admin", text("admin")),
+	)
+	handleTestEndpoints(t, srv, &EchoHandler{}, []EndpointMetadata{
+		{Name: "Echo.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+	}, HandlerHosts("rpc.example.com"))
+	c := startTestServer(t, srv)
+
+	do := func(host, path string) (int, string) {
+		req := c.request(http.MethodGet, path, nil)
+		req.Host = host
+		rsp, body := c.do(req)
+		return rsp.StatusCode, body
+	}
+
+	code, body := do("localhost", "/api/v1/items")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "any /items", body)
+
+	code, _ = do("localhost", "/items")
+	require.Equal(t, http.StatusNotFound, code)
+
+	_, body = do("www.example.com", "/api/v1/items")
+	require.Equal(t, "wildcard /items", body)
+
+	_, body = do("admin.example.com:80", "/api/v1/items")
+	require.Equal(t, "admin /items", body)
+
+	code, _ = do("www.example.com", "/api/v1/admin")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = do("localhost", "/api/v1/v1/name")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, body = do("rpc.example.com", "/api/v1/v1/name")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"name":"name"}`, body)
+
+	var paths []string
+	for _, r := range srv.Routes() {
+		paths = append(paths, r.Host+r.Path)
+	}
+	require.Equal(t, []string{
+		"admin.example.com/api/v1/admin",
+		"/api/v1/items",
+		"*.example.com/api/v1/items",
+		"admin.example.com/api/v1/items",
+		"rpc.example.com/api/v1/v1/{name}",
+	}, paths)
+
+	svcs, err := reg.LookupService(context.Background(), "test")
+	require.NoError(t, err)
+	routes, err := RoutesFromMetadata(svcs[0].Nodes[0].Metadata)
+	require.NoError(t, err)
+	require.Equal(t, "/api/v1/admin", routes[0].Path)
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	rhttp "go.unistack.org/micro/v4/util/http"
+)
+
+// cleanPath removes duplicate slashes and resolves . and .. elements, trailing slash preserved
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cp := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cp != "/" {
+		cp += "/"
+	}
+	return cp
+}
+
+// toggleTrailingSlash adds trailing slash to path or removes it
+func toggleTrailingSlash(p string) string {
+	if p == "/" {
+		return p
+	}
+	if strings.HasSuffix(p, "/") {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
+
+// exists reports whether any route for host and path registered, path registered
+// for other methods than request one reported by trie as not allowed
+func (rt *routeTable) exists(host, method, p string) bool {
+	if _, _, _, err := rt.searchHandlers(host, method, p); err == nil || err == rhttp.ErrMethodNotAllowed {
+		return true
+	}
+	_, _, err := rt.searchPath(host, method, p)
+	return err == nil || err == rhttp.ErrMethodNotAllowed
+}
+
+// fixCase returns path with literal segments cased like in registered route template
+func (rt *routeTable) fixCase(host, method, p string) (string, bool) {
+	for _, fp := range rt.foldedPaths[strings.ToLower(p)] {
+		if rt.exists(host, method, fp) {
+			return fp, true
+		}
+	}
+
+	segs := strings.Split(p, "/")
+
+	for _, tsegs := range rt.foldedParams[len(segs)] {
+		fixed := make([]string, len(segs))
+		ok := true
+		for i, ts := range tsegs {
+			switch {
+			case strings.HasPrefix(ts, "{") && strings.HasSuffix(ts, "}"):
+				fixed[i] = segs[i]
+			case strings.EqualFold(ts, segs[i]):
+				fixed[i] = ts
+			default:
+				ok = false
+			}
+			if !ok {
+				break
+			}
+		}
+
+		if fp := strings.Join(fixed, "/"); ok && rt.exists(host, method, fp) {
+			return fp, true
+		}
+	}
+
+	return "", false
+}
+
+// normalizePath returns path that has registered route, redirect is true if client should be redirected to it,
+// otherwise request served with returned path
+func (h *Server) normalizePath(rt *routeTable, host, method, p string) (string, bool) {
+	if !h.cleanPath && !h.redirectTrailingSlash && !h.caseInsensitive || rt.exists(host, method, p) {
+		return p, false
+	}
+
+	candidates := []string{p}
+	if h.cleanPath {
+		if cp := cleanPath(p); cp != p {
+			candidates[0] = cp
+		}
+	}
+	if h.redirectTrailingSlash {
+		candidates = append(candidates, toggleTrailingSlash(candidates[0]))
+	}
+
+	for _, c := range candidates {
+		if c != p && rt.exists(host, method, c) {
+			return c, true
+		}
+	}
+
+	if h.caseInsensitive {
+		for _, c := range candidates {
+			if fp, ok := rt.fixCase(host, method, c); ok {
+				// legacy clients served as is, only other fixes redirected
+				return fp, c != p
+			}
+		}
+	}
+
+	return p, false
+}
+
+// redirectPath redirects client to path, method and body kept for non GET requests
+func redirectPath(w http.ResponseWriter, r *http.Request, p string) {
+	code := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+	u := *r.URL
+	u.Path = p
+	u.RawPath = ""
+	http.Redirect(w, r, u.RequestURI(), code)
+}
+
+// withPath returns shallow copy of request with path replaced
+func withPath(r *http.Request, p string) *http.Request {
+	nr := r.WithContext(r.Context())
+	u := *r.URL
+	u.Path = p
+	u.RawPath = ""
+	nr.URL = &u
+	return nr
+}
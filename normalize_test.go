@@ -0,0 +1,103 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanPath(t *testing.T) {
+	for in, out := range map[string]string{
+		"":            "/",
+		"/":           "/",
+		"//a//b":      "/a/b",
+		"/a/./b/":     "/a/b/",
+		"/a/../b":     "/b",
+		"/a/b/../../": "/",
+	} {
+		require.Equal(t, out, cleanPath(in), in)
+	}
+}
+
+func TestServerPathNormalization(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}
+	srv := newTestServer(t,
+		RedirectTrailingSlash(true),
+		CleanPath(true),
+		CaseInsensitive(true),
+		PathHandler(http.MethodGet, "/items", echo),
+		PathHandler(http.MethodPost, "/items", echo),
+		PathHandler(http.MethodGet, "/dir/", echo),
+		PathHandler(http.MethodGet, "/Users/{id}", echo),
+	)
+	c := startTestServer(t, srv)
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	do := func(method, path string) (*http.Response, string) {
+		return c.do(c.request(method, path, nil))
+	}
+
+	rsp, body := do(http.MethodGet, "/items")
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.Equal(t, "/items", body)
+
+	rsp, _ = do(http.MethodGet, "/items/?q=1")
+	require.Equal(t, http.StatusMovedPermanently, rsp.StatusCode)
+	require.Equal(t, "/items?q=1", rsp.Header.Get("Location"))
+
+	rsp, _ = do(http.MethodPost, "/items/")
+	require.Equal(t, http.StatusPermanentRedirect, rsp.StatusCode)
+	require.Equal(t, "/items", rsp.Header.Get("Location"))
+
+	rsp, _ = do(http.MethodGet, "/dir")
+	require.Equal(t, http.StatusMovedPermanently, rsp.StatusCode)
+	require.Equal(t, "/dir/", rsp.Header.Get("Location"))
+
+	// path registered for other method still redirected, then answered with 405
+	rsp, _ = do(http.MethodPut, "/dir")
+	require.Equal(t, http.StatusPermanentRedirect, rsp.StatusCode)
+	require.Equal(t, "/dir/", rsp.Header.Get("Location"))
+
+	rsp, _ = do(http.MethodGet, "//dir/../items")
+	require.Equal(t, http.StatusMovedPermanently, rsp.StatusCode)
+	require.Equal(t, "/items", rsp.Header.Get("Location"))
+
+	// case only differences served without redirect
+	rsp, body = do(http.MethodGet, "/users/Abc")
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.Equal(t, "/Users/Abc", body)
+
+	rsp, _ = do(http.MethodGet, "/ITEMS/")
+	require.Equal(t, http.StatusMovedPermanently, rsp.StatusCode)
+	require.Equal(t, "/items", rsp.Header.Get("Location"))
+
+	rsp, _ = do(http.MethodGet, "/unknown/")
+	require.Equal(t, http.StatusNotFound, rsp.StatusCode)
+
+	// case index rebuilt on route changes
+	require.NoError(t, srv.AddPathHandler(http.MethodGet, "/Orders", echo))
+	rsp, body = do(http.MethodGet, "/orders")
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.Equal(t, "/Orders", body)
+
+	require.NoError(t, srv.RemovePathHandler(http.MethodGet, "/Orders"))
+	rsp, _ = do(http.MethodGet, "/orders")
+	require.Equal(t, http.StatusNotFound, rsp.StatusCode)
+}
+
+func TestServerPathNormalizationDisabled(t *testing.T) {
+	srv := newTestServer(t,
+		PathHandler(http.MethodGet, "/items", func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	c := startTestServer(t, srv)
+
+	for _, path := range []string{"/items/", "/ITEMS", "//items"} {
+		code, _ := c.get(path)
+		require.Equal(t, http.StatusNotFound, code, path)
+	}
+}
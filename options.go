@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
+	"github.com/quic-go/quic-go/http3"
 	"go.unistack.org/micro/v4/server"
+	"golang.org/x/net/http2"
 )
 
 // SetError pass error to caller
@@ -33,7 +37,8 @@ func (err *Error) Error() string {
 
 type middlewareKey struct{}
 
-// Middleware passes http middlewares
+// Middleware passes http middlewares applied to all requests, route resolved before them,
+// so middlewares can use RouteInfoFromContext
 func Middleware(mw ...func(http.Handler) http.Handler) server.Option {
 	return server.SetOption(middlewareKey{}, mw)
 }
@@ -45,6 +50,138 @@ func HTTPServer(hs *http.Server) server.Option {
 	return server.SetOption(serverKey{}, hs)
 }
 
+type http2ServerKey struct{}
+
+// HTTP2Server provide ability to pass *http2.Server with tuned settings like
+// MaxConcurrentStreams, MaxReadFrameSize and IdleTimeout
+func HTTP2Server(h2s *http2.Server) server.Option {
+	return server.SetOption(http2ServerKey{}, h2s)
+}
+
+type listenersKey struct{}
+
+// Listen adds listener served by server in addition to main one
+func Listen(cfg ListenerConfig) server.Option {
+	return func(o *server.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		v, _ := o.Context.Value(listenersKey{}).([]ListenerConfig)
+		o.Context = context.WithValue(o.Context, listenersKey{}, append(slices.Clone(v), cfg))
+	}
+}
+
+type inheritListenersKey struct{}
+
+// InheritListeners enables usage of listeners passed via systemd socket activation (LISTEN_FDS)
+// or by parent process via Upgrade, listener matched by network and address
+func InheritListeners(b bool) server.Option {
+	return server.SetOption(inheritListenersKey{}, b)
+}
+
+type reusePortKey struct{}
+
+// ReusePort enables SO_REUSEPORT on created tcp listeners, so several processes can bind the same address
+func ReusePort(b bool) server.Option {
+	return server.SetOption(reusePortKey{}, b)
+}
+
+type http3ServerKey struct{}
+
+// HTTP3Server enables HTTP/3 (QUIC) listener next to tcp one, requires server TLSConfig.
+// If hs.Addr is empty, udp listener uses the same address as tcp listener.
+// Handler and TLSConfig filled by server if not set.
+func HTTP3Server(hs *http3.Server) server.Option {
+	return server.SetOption(http3ServerKey{}, hs)
+}
+
+type tlsCertificatesKey struct{}
+
+// TLSCertificates loads tls certificates from files and reloads them on change without restart,
+// certificate selected by SNI, other settings taken from server TLSConfig
+func TLSCertificates(cfg CertificateConfig) server.Option {
+	return server.SetOption(tlsCertificatesKey{}, cfg)
+}
+
+type preStopDelayKey struct{}
+
+// PreStopDelay specifies how long server waits after marking itself not ready and before stop accepting
+// new requests, so balancers like kubernetes endpoints observe readiness change
+func PreStopDelay(td time.Duration) server.Option {
+	return server.SetOption(preStopDelayKey{}, td)
+}
+
+// HealthCheckFunc checks server dependency and returns error if it not healthy
+type HealthCheckFunc func(context.Context) error
+
+type healthChecksKey struct{}
+
+// HealthChecks adds checks that run before each registration, server deregistered while any check fails
+func HealthChecks(fns ...HealthCheckFunc) server.Option {
+	return func(o *server.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		v, _ := o.Context.Value(healthChecksKey{}).([]HealthCheckFunc)
+		o.Context = context.WithValue(o.Context, healthChecksKey{}, append(slices.Clone(v), fns...))
+	}
+}
+
+type (
+	beforeStartKey struct{}
+	afterStartKey  struct{}
+	beforeStopKey  struct{}
+	afterStopKey   struct{}
+)
+
+func appendLifecycleHook(key interface{}, fn LifecycleHook) server.Option {
+	return func(o *server.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		v, _ := o.Context.Value(key).([]LifecycleHook)
+		o.Context = context.WithValue(o.Context, key, append(slices.Clone(v), fn))
+	}
+}
+
+// BeforeStart adds hook that runs before server creates listeners, error aborts Start
+func BeforeStart(fn LifecycleHook) server.Option {
+	return appendLifecycleHook(beforeStartKey{}, fn)
+}
+
+// AfterStart adds hook that runs after server starts serving and before it marked ready,
+// error aborts Start and stops server, BeforeStop hooks skipped in that case and AfterStop hooks run
+func AfterStart(fn LifecycleHook) server.Option {
+	return appendLifecycleHook(afterStartKey{}, fn)
+}
+
+// BeforeStop adds hook that runs after server marked not ready and before it deregistered and drained,
+// it runs only if AfterStart hooks completed
+func BeforeStop(fn LifecycleHook) server.Option {
+	return appendLifecycleHook(beforeStopKey{}, fn)
+}
+
+// AfterStop adds hook that runs after server drained and closed, error returned from Stop,
+// it runs also if AfterStart hooks aborted Start
+func AfterStop(fn LifecycleHook) server.Option {
+	return appendLifecycleHook(afterStopKey{}, fn)
+}
+
+type h2cKey struct{}
+
+// H2C enables HTTP/2 cleartext (h2c) with prior knowledge and upgrade from HTTP/1.1
+func H2C(b bool) server.Option {
+	return server.SetOption(h2cKey{}, b)
+}
+
+type proxyProtocolKey struct{}
+
+// ProxyProtocol enables PROXY protocol v1/v2 decoding on server listener,
+// so client address from header available as r.RemoteAddr
+func ProxyProtocol(cfg ProxyProtocolConfig) server.Option {
+	return server.SetOption(proxyProtocolKey{}, cfg)
+}
+
 type errorHandler func(ctx context.Context, s server.Handler, w http.ResponseWriter, r *http.Request, err error, status int)
 
 type errorHandlerKey struct{}
@@ -57,30 +194,83 @@ func ErrorHandler(fn errorHandler) server.Option {
 type (
 	pathHandlerKey struct{}
 	pathHandlerVal struct {
-		h map[string]map[string]http.HandlerFunc
+		h map[string]map[string]map[string]http.HandlerFunc
 	}
 )
 
 // PathHandler specifies http handler for path regexp
 func PathHandler(method, path string, handler http.HandlerFunc) server.Option {
+	return HostPathHandler("", method, path, handler)
+}
+
+// HostPathHandler specifies http handler for path regexp served only for host,
+// host can be wildcard subdomain like *.example.com
+func HostPathHandler(host, method, path string, handler http.HandlerFunc) server.Option {
 	return func(o *server.Options) {
 		if o.Context == nil {
 			o.Context = context.Background()
 		}
 		v, ok := o.Context.Value(pathHandlerKey{}).(*pathHandlerVal)
 		if !ok {
-			v = &pathHandlerVal{h: make(map[string]map[string]http.HandlerFunc)}
+			v = &pathHandlerVal{h: make(map[string]map[string]map[string]http.HandlerFunc)}
 		}
-		m, ok := v.h[method]
+		ms, ok := v.h[host]
+		if !ok {
+			ms = make(map[string]map[string]http.HandlerFunc)
+			v.h[host] = ms
+		}
+		m, ok := ms[method]
 		if !ok {
 			m = make(map[string]http.HandlerFunc)
-			v.h[method] = m
+			ms[method] = m
 		}
 		m[path] = handler
 		o.Context = context.WithValue(o.Context, pathHandlerKey{}, v)
 	}
 }
 
+type legacyRequestMetadataKey struct{}
+
+// LegacyRequestMetadata injects request properties like Method, URL, Host, RemoteAddr and Scheme
+// to incoming metadata as before RequestInfoFromContext added, values sent by client in headers
+// with the same names replaced
+func LegacyRequestMetadata(b bool) server.Option {
+	return server.SetOption(legacyRequestMetadataKey{}, b)
+}
+
+type strictQueryKey struct{}
+
+// StrictQuery rejects requests with query parameters not matching fields of proto message endpoint argument,
+// response status 400 with list of unknown parameters
+func StrictQuery(b bool) server.Option {
+	return server.SetOption(strictQueryKey{}, b)
+}
+
+type strictDecodingKey struct{}
+
+// StrictDecoding rejects json request bodies with unknown fields, duplicate keys or trailing data,
+// response status 400 names offending field, json bodies decoded by protojson or encoding/json instead of codec
+func StrictDecoding(b bool) server.Option {
+	return server.SetOption(strictDecodingKey{}, b)
+}
+
+type partialResponseKey struct{}
+
+// PartialResponse prunes reply to fields selected by comma separated paths like name,items.id
+// in fields query parameter or X-Fields header, repeated and map fields pruned per element,
+// unknown paths rejected with status 400
+func PartialResponse(b bool) server.Option {
+	return server.SetOption(partialResponseKey{}, b)
+}
+
+type basePathKey struct{}
+
+// BasePath specifies prefix like /api/v1 for all handlers and path handlers,
+// requests outside of it not served, prefix stripped from request path before routing
+func BasePath(p string) server.Option {
+	return server.SetOption(basePathKey{}, p)
+}
+
 type registerRPCHandlerKey struct{}
 
 // RegisterRPCHandler registers compatibility endpoints with /ServiceName.ServiceEndpoint method POST
@@ -88,6 +278,28 @@ func RegisterRPCHandler(b bool) server.Option {
 	return server.SetOption(registerRPCHandlerKey{}, b)
 }
 
+type redirectTrailingSlashKey struct{}
+
+// RedirectTrailingSlash redirects /x to /x/ and vice versa if only one of them registered,
+// GET and HEAD redirected with 301, other methods with 308
+func RedirectTrailingSlash(b bool) server.Option {
+	return server.SetOption(redirectTrailingSlashKey{}, b)
+}
+
+type cleanPathKey struct{}
+
+// CleanPath redirects requests with duplicate slashes and . or .. elements to clean path
+func CleanPath(b bool) server.Option {
+	return server.SetOption(cleanPathKey{}, b)
+}
+
+type caseInsensitiveKey struct{}
+
+// CaseInsensitive serves requests with path that differs from registered route only in case
+func CaseInsensitive(b bool) server.Option {
+	return server.SetOption(caseInsensitiveKey{}, b)
+}
+
 type registerCORSHandlerKey struct{}
 
 // RegisterCORSHandler registers cors endpoints with /ServiceName.ServiceEndpoint method POPTIONSOST
@@ -95,6 +307,84 @@ func RegisterCORSHandler(b bool) server.HandlerOption {
 	return server.SetHandlerOption(registerCORSHandlerKey{}, b)
 }
 
+// HandlerStrictDecoding enables or disables StrictDecoding for handler endpoints, overrides server option
+func HandlerStrictDecoding(b bool) server.HandlerOption {
+	return server.SetHandlerOption(strictDecodingKey{}, b)
+}
+
+type handlerMiddlewareKey struct{}
+
+// HandlerMiddleware passes http middlewares applied to all handler endpoints after route matched,
+// so middlewares can use RouteInfoFromContext
+func HandlerMiddleware(mw ...func(http.Handler) http.Handler) server.HandlerOption {
+	return func(o *server.HandlerOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		v, _ := o.Context.Value(handlerMiddlewareKey{}).([]func(http.Handler) http.Handler)
+		o.Context = context.WithValue(o.Context, handlerMiddlewareKey{}, append(slices.Clone(v), mw...))
+	}
+}
+
+type endpointMiddlewareKey struct{}
+
+// EndpointMiddleware passes http middlewares applied to single handler endpoint like Service.Method
+// after handler middlewares
+func EndpointMiddleware(endpoint string, mw ...func(http.Handler) http.Handler) server.HandlerOption {
+	return func(o *server.HandlerOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		v, _ := o.Context.Value(endpointMiddlewareKey{}).(map[string][]func(http.Handler) http.Handler)
+		nv := make(map[string][]func(http.Handler) http.Handler, len(v)+1)
+		for k, mws := range v {
+			nv[k] = mws
+		}
+		nv[endpoint] = append(slices.Clone(nv[endpoint]), mw...)
+		o.Context = context.WithValue(o.Context, endpointMiddlewareKey{}, nv)
+	}
+}
+
+type pathMiddlewareKey struct{}
+
+// PathMiddleware passes http middlewares applied to path handler for method and path template
+// after route matched, for any host
+func PathMiddleware(method, path string, mw ...func(http.Handler) http.Handler) server.Option {
+	return func(o *server.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		v, _ := o.Context.Value(pathMiddlewareKey{}).(map[string][]func(http.Handler) http.Handler)
+		nv := make(map[string][]func(http.Handler) http.Handler, len(v)+1)
+		for k, mws := range v {
+			nv[k] = mws
+		}
+		nv[method+" "+path] = append(slices.Clone(nv[method+" "+path]), mw...)
+		o.Context = context.WithValue(o.Context, pathMiddlewareKey{}, nv)
+	}
+}
+
+type handlerBindingsKey struct{}
+
+// HandlerBinding binds proto request field to header, cookie or path parameter like struct tags
+// header:"X-Tenant,required" do for go structs, field is dot separated path of proto or json field names
+func HandlerBinding(field, source, tag string) server.HandlerOption {
+	return func(o *server.HandlerOptions) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		v, _ := o.Context.Value(handlerBindingsKey{}).([]bindField)
+		o.Context = context.WithValue(o.Context, handlerBindingsKey{}, append(slices.Clone(v), newBindField(source, tag, field, nil)))
+	}
+}
+
+type handlerHostsKey struct{}
+
+// HandlerHosts binds handler to hosts, host can be wildcard subdomain like *.example.com
+func HandlerHosts(hosts ...string) server.HandlerOption {
+	return server.SetHandlerOption(handlerHostsKey{}, hosts)
+}
+
 type handlerEndpointsKey struct{}
 
 type EndpointMetadata struct {
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/textproto"
+
+	"go.unistack.org/micro/v4/metadata"
+)
+
+// Metadata keys filled from verified client certificate
+const (
+	MetadataPrincipal    = "TLS-Peer-Principal"
+	MetadataPeerSubject  = "TLS-Peer-Subject"
+	MetadataPeerDNSNames = "TLS-Peer-DNS-Names"
+	MetadataPeerEmails   = "TLS-Peer-Emails"
+	MetadataPeerIPs      = "TLS-Peer-IPs"
+	MetadataPeerURIs     = "TLS-Peer-URIs"
+	MetadataPeerSPIFFEID = "TLS-Peer-SPIFFE-ID"
+	spiffeScheme         = "spiffe"
+)
+
+var principalMetadataKeys = []string{
+	MetadataPrincipal,
+	MetadataPeerSubject,
+	MetadataPeerDNSNames,
+	MetadataPeerEmails,
+	MetadataPeerIPs,
+	MetadataPeerURIs,
+	MetadataPeerSPIFFEID,
+}
+
+// Principal holds identity of client authenticated by mutual tls
+type Principal struct {
+	// Certificate is verified client leaf certificate
+	Certificate    *x509.Certificate
+	Subject        string
+	CommonName     string
+	SPIFFEID       string
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []string
+	URIs           []string
+}
+
+// Name returns SPIFFE ID if present, otherwise subject common name
+func (p *Principal) Name() string {
+	if p.SPIFFEID != "" {
+		return p.SPIFFEID
+	}
+	return p.CommonName
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns principal of client authenticated by mutual tls
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// NewPrincipalContext returns context with principal
+func NewPrincipalContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// principalFromTLS returns principal only for client certificate verified by server
+func principalFromTLS(cs *tls.ConnectionState) *Principal {
+	if cs == nil || len(cs.VerifiedChains) == 0 || len(cs.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	crt := cs.VerifiedChains[0][0]
+	p := &Principal{
+		Certificate:    crt,
+		Subject:        crt.Subject.String(),
+		CommonName:     crt.Subject.CommonName,
+		DNSNames:       crt.DNSNames,
+		EmailAddresses: crt.EmailAddresses,
+	}
+	for _, ip := range crt.IPAddresses {
+		p.IPAddresses = append(p.IPAddresses, ip.String())
+	}
+	for _, u := range crt.URIs {
+		p.URIs = append(p.URIs, u.String())
+		// spiffe allows exactly one uri san with spiffe scheme
+		if u.Scheme == spiffeScheme && p.SPIFFEID == "" {
+			p.SPIFFEID = u.String()
+		}
+	}
+
+	return p
+}
+
+// withPrincipal removes principal metadata copied from request headers, so client can't spoof it,
+// and fills it from verified client certificate
+func withPrincipal(ctx context.Context, md metadata.Metadata, cs *tls.ConnectionState) (context.Context, *Principal) {
+	for _, k := range principalMetadataKeys {
+		delete(md, k)
+		delete(md, textproto.CanonicalMIMEHeaderKey(k))
+	}
+
+	p := principalFromTLS(cs)
+	if p == nil {
+		return ctx, nil
+	}
+
+	md[MetadataPrincipal] = []string{p.Name()}
+	md[MetadataPeerSubject] = []string{p.Subject}
+	if len(p.DNSNames) > 0 {
+		md[MetadataPeerDNSNames] = p.DNSNames
+	}
+	if len(p.EmailAddresses) > 0 {
+		md[MetadataPeerEmails] = p.EmailAddresses
+	}
+	if len(p.IPAddresses) > 0 {
+		md[MetadataPeerIPs] = p.IPAddresses
+	}
+	if len(p.URIs) > 0 {
+		md[MetadataPeerURIs] = p.URIs
+	}
+	if p.SPIFFEID != "" {
+		md[MetadataPeerSPIFFEID] = []string{p.SPIFFEID}
+	}
+
+	return NewPrincipalContext(ctx, p), p
+}
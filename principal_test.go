@@ -0,0 +1,71 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/metadata"
+	"go.unistack.org/micro/v4/server"
+)
+
+func TestServerPrincipal(t *testing.T) {
+	srvCrt, srvLeaf := newTestCertificate(t, "127.0.0.1")
+	cliCrt, cliLeaf := newTestCertificate(t, "client", "spiffe://example.org/ns/default/sa/client", "client@example.org")
+
+	srvPool := x509.NewCertPool()
+	srvPool.AddCert(srvLeaf)
+	cliPool := x509.NewCertPool()
+	cliPool.AddCert(cliLeaf)
+
+	srv := NewServer(
+		server.Address("127.0.0.1:0"),
+		server.TLSConfig(&tls.Config{
+			Certificates: []tls.Certificate{srvCrt},
+			ClientCAs:    cliPool,
+			ClientAuth:   tls.VerifyClientCertIfGiven,
+		}),
+		PathHandler(http.MethodGet, "/principal", func(w http.ResponseWriter, r *http.Request) {
+			md, _ := metadata.FromIncomingContext(r.Context())
+			p, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				_, _ = w.Write([]byte("anonymous " + strings.Join(md.Get(MetadataPrincipal), ",")))
+				return
+			}
+			_, _ = w.Write([]byte(p.Name() + " " + p.CommonName + " " + strings.Join(p.EmailAddresses, ",") + " " + strings.Join(md.Get(MetadataPeerSPIFFEID), ",")))
+		}),
+	)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+	defer func() {
+		require.NoError(t, srv.Stop())
+	}()
+
+	get := func(c *http.Client, hdr string) string {
+		req, err := http.NewRequest(http.MethodGet, "https://"+srv.Options().Address+"/principal", nil)
+		require.NoError(t, err)
+		if hdr != "" {
+			req.Header.Set(MetadataPrincipal, hdr)
+		}
+		rsp, err := c.Do(req)
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		buf, err := io.ReadAll(rsp.Body)
+		require.NoError(t, err)
+		return string(buf)
+	}
+
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      srvPool,
+		Certificates: []tls.Certificate{cliCrt},
+	}}}
+	require.Equal(t, "spiffe://example.org/ns/default/sa/client client client@example.org spiffe://example.org/ns/default/sa/client", get(c, ""))
+
+	// principal header from client without certificate ignored
+	c = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: srvPool}}}
+	require.Equal(t, "anonymous ", get(c, "spiffe://example.org/admin"))
+}
@@ -0,0 +1,339 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrProxyProtocolHeaderMissing returned when PROXY protocol header required but not sent by peer
+	ErrProxyProtocolHeaderMissing = errors.New("proxy protocol header missing")
+	// ErrProxyProtocolHeaderInvalid returned when PROXY protocol header can't be parsed
+	ErrProxyProtocolHeaderInvalid = errors.New("proxy protocol header invalid")
+)
+
+// DefaultProxyProtocolReadHeaderTimeout specifies max time to wait for PROXY protocol header
+var DefaultProxyProtocolReadHeaderTimeout = 5 * time.Second
+
+var (
+	proxyProtocolV1Signature = []byte("PROXY ")
+	proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+const (
+	// max v1 header length including CRLF
+	proxyProtocolV1MaxLen = 107
+	// v2 fixed header length: signature + ver/cmd + fam + len
+	proxyProtocolV2HeaderLen = 16
+)
+
+// ProxyProtocolPolicy specifies how listener handles PROXY protocol header
+type ProxyProtocolPolicy int
+
+const (
+	// ProxyProtocolOptional parses header if it present, otherwise uses connection addresses
+	ProxyProtocolOptional ProxyProtocolPolicy = iota
+	// ProxyProtocolRequired closes connections from trusted sources that not sends header
+	ProxyProtocolRequired
+)
+
+// ProxyProtocolConfig holds PROXY protocol listener settings
+type ProxyProtocolConfig struct {
+	// TrustedSources contains ip addresses or cidrs allowed to send PROXY protocol header,
+	// if empty all sources are trusted. Connections from untrusted sources used as is.
+	TrustedSources []string
+	// ReadHeaderTimeout specifies max time to wait for header, DefaultProxyProtocolReadHeaderTimeout if zero
+	ReadHeaderTimeout time.Duration
+	// Policy specifies header policy for trusted sources
+	Policy ProxyProtocolPolicy
+}
+
+type proxyProtocolListener struct {
+	net.Listener
+	trusted []*net.IPNet
+	timeout time.Duration
+	policy  ProxyProtocolPolicy
+}
+
+// newProxyProtocolListener wraps listener with PROXY protocol v1/v2 decoder
+func newProxyProtocolListener(l net.Listener, cfg ProxyProtocolConfig) (net.Listener, error) {
+	pl := &proxyProtocolListener{
+		Listener: l,
+		policy:   cfg.Policy,
+		timeout:  cfg.ReadHeaderTimeout,
+	}
+	if pl.timeout <= 0 {
+		pl.timeout = DefaultProxyProtocolReadHeaderTimeout
+	}
+
+	for _, src := range cfg.TrustedSources {
+		if !strings.Contains(src, "/") {
+			ip := net.ParseIP(src)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy protocol trusted source %s", src)
+			}
+			if ip.To4() != nil {
+				src += "/32"
+			} else {
+				src += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy protocol trusted source %s: %w", src, err)
+		}
+		pl.trusted = append(pl.trusted, ipnet)
+	}
+
+	return pl, nil
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.isTrusted(c.RemoteAddr()) {
+		return c, nil
+	}
+
+	return &proxyProtocolConn{
+		Conn:    c,
+		br:      bufio.NewReader(c),
+		policy:  l.policy,
+		timeout: l.timeout,
+	}, nil
+}
+
+func (l *proxyProtocolListener) isTrusted(addr net.Addr) bool {
+	if len(l.trusted) == 0 {
+		return true
+	}
+
+	var ip net.IP
+	switch v := addr.(type) {
+	case *net.TCPAddr:
+		ip = v.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return false
+		}
+		ip = net.ParseIP(host)
+	}
+	if ip == nil {
+		return false
+	}
+
+	for _, ipnet := range l.trusted {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// proxyProtocolConn reads PROXY protocol header on first use of RemoteAddr, LocalAddr or Read.
+// net/http calls RemoteAddr in per connection goroutine, so Accept loop never blocks on slow peers.
+type proxyProtocolConn struct {
+	net.Conn
+	br      *bufio.Reader
+	srcAddr net.Addr
+	dstAddr net.Addr
+	err     error
+	timeout time.Duration
+	once    sync.Once
+	policy  ProxyProtocolPolicy
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.br.Read(b)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.srcAddr != nil {
+		return c.srcAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.dstAddr != nil {
+		return c.dstAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	if c.err = c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); c.err != nil {
+		return
+	}
+
+	c.srcAddr, c.dstAddr, c.err = readProxyProtocolHeader(c.br)
+	if errors.Is(c.err, ErrProxyProtocolHeaderMissing) && c.policy == ProxyProtocolOptional {
+		c.err = nil
+	}
+
+	if c.err != nil {
+		_ = c.Conn.Close()
+		return
+	}
+
+	c.err = c.Conn.SetReadDeadline(time.Time{})
+}
+
+// readProxyProtocolHeader parses v1 or v2 header from reader.
+// nil addresses with nil error returned for v1 UNKNOWN and v2 LOCAL commands.
+func readProxyProtocolHeader(br *bufio.Reader) (net.Addr, net.Addr, error) {
+	buf, err := br.Peek(1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch buf[0] {
+	case proxyProtocolV1Signature[0]:
+		if buf, err = br.Peek(len(proxyProtocolV1Signature)); err != nil || !bytes.Equal(buf, proxyProtocolV1Signature) {
+			return nil, nil, ErrProxyProtocolHeaderMissing
+		}
+		return readProxyProtocolV1(br)
+	case proxyProtocolV2Signature[0]:
+		if buf, err = br.Peek(len(proxyProtocolV2Signature)); err != nil || !bytes.Equal(buf, proxyProtocolV2Signature) {
+			return nil, nil, ErrProxyProtocolHeaderMissing
+		}
+		return readProxyProtocolV2(br)
+	}
+
+	return nil, nil, ErrProxyProtocolHeaderMissing
+}
+
+func readProxyProtocolV1(br *bufio.Reader) (net.Addr, net.Addr, error) {
+	line := make([]byte, 0, proxyProtocolV1MaxLen)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyProtocolV1MaxLen {
+			return nil, nil, ErrProxyProtocolHeaderInvalid
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, ErrProxyProtocolHeaderInvalid
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) < 2 {
+		return nil, nil, ErrProxyProtocolHeaderInvalid
+	}
+
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, nil, ErrProxyProtocolHeaderInvalid
+	}
+
+	if len(fields) != 6 {
+		return nil, nil, ErrProxyProtocolHeaderInvalid
+	}
+
+	srcIP := net.ParseIP(fields[2])
+	dstIP := net.ParseIP(fields[3])
+	if srcIP == nil || dstIP == nil || (fields[1] == "TCP4") != (srcIP.To4() != nil) {
+		return nil, nil, ErrProxyProtocolHeaderInvalid
+	}
+
+	srcPort, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, nil, ErrProxyProtocolHeaderInvalid
+	}
+	dstPort, err := strconv.ParseUint(fields[5], 10, 16)
+	if err != nil {
+		return nil, nil, ErrProxyProtocolHeaderInvalid
+	}
+
+	return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, &net.TCPAddr{IP: dstIP, Port: int(dstPort)}, nil
+}
+
+func readProxyProtocolV2(br *bufio.Reader) (net.Addr, net.Addr, error) {
+	hdr := make([]byte, proxyProtocolV2HeaderLen)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, nil, err
+	}
+
+	if hdr[12]>>4 != 0x2 {
+		return nil, nil, ErrProxyProtocolHeaderInvalid
+	}
+
+	cmd := hdr[12] & 0x0f
+	fam := hdr[13] >> 4
+	proto := hdr[13] & 0x0f
+
+	payload := make([]byte, binary.BigEndian.Uint16(hdr[14:16]))
+	if _, err := io.ReadFull(br, payload); err != nil {
+		return nil, nil, err
+	}
+
+	switch cmd {
+	case 0x0: // LOCAL, health checks from proxy itself
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, ErrProxyProtocolHeaderInvalid
+	}
+
+	// only stream transport carries meaningful addresses for http
+	if proto != 0x1 {
+		return nil, nil, nil
+	}
+
+	switch fam {
+	case 0x1: // AF_INET
+		if len(payload) < 12 {
+			return nil, nil, ErrProxyProtocolHeaderInvalid
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))},
+			&net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))},
+			nil
+	case 0x2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, nil, ErrProxyProtocolHeaderInvalid
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))},
+			&net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))},
+			nil
+	case 0x3: // AF_UNIX
+		if len(payload) < 216 {
+			return nil, nil, ErrProxyProtocolHeaderInvalid
+		}
+		return &net.UnixAddr{Net: "unix", Name: string(bytes.TrimRight(payload[0:108], "\x00"))},
+			&net.UnixAddr{Net: "unix", Name: string(bytes.TrimRight(payload[108:216], "\x00"))},
+			nil
+	}
+
+	// AF_UNSPEC
+	return nil, nil, nil
+}
@@ -0,0 +1,191 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func proxyProtocolV2Header(src, dst *net.TCPAddr) []byte {
+	buf := bytes.NewBuffer(nil)
+	buf.Write(proxyProtocolV2Signature)
+	buf.WriteByte(0x21) // v2, PROXY
+	buf.WriteByte(0x11) // AF_INET, STREAM
+	_ = binary.Write(buf, binary.BigEndian, uint16(12))
+	buf.Write(src.IP.To4())
+	buf.Write(dst.IP.To4())
+	_ = binary.Write(buf, binary.BigEndian, uint16(src.Port))
+	_ = binary.Write(buf, binary.BigEndian, uint16(dst.Port))
+	return buf.Bytes()
+}
+
+func TestReadProxyProtocolHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		src  string
+		dst  string
+		rest string
+		err  error
+	}{
+		{
+			name: "v1 tcp4",
+			data: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET / HTTP/1.1\r\n"),
+			src:  "192.0.2.1:56324",
+			dst:  "198.51.100.1:443",
+			rest: "GET / HTTP/1.1\r\n",
+		},
+		{
+			name: "v1 tcp6",
+			data: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"),
+			src:  "[2001:db8::1]:56324",
+			dst:  "[2001:db8::2]:443",
+		},
+		{
+			name: "v1 unknown",
+			data: []byte("PROXY UNKNOWN\r\n"),
+		},
+		{
+			name: "v1 invalid port",
+			data: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 port 443\r\n"),
+			err:  ErrProxyProtocolHeaderInvalid,
+		},
+		{
+			name: "v1 family mismatch",
+			data: []byte("PROXY TCP4 2001:db8::1 2001:db8::2 56324 443\r\n"),
+			err:  ErrProxyProtocolHeaderInvalid,
+		},
+		{
+			name: "v2 tcp4",
+			data: append(proxyProtocolV2Header(
+				&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324},
+				&net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443},
+			), []byte("GET / HTTP/1.1\r\n")...),
+			src:  "192.0.2.1:56324",
+			dst:  "198.51.100.1:443",
+			rest: "GET / HTTP/1.1\r\n",
+		},
+		{
+			name: "v2 local",
+			data: append(append([]byte{}, proxyProtocolV2Signature...), 0x20, 0x00, 0x00, 0x00),
+		},
+		{
+			name: "no header",
+			data: []byte("POST / HTTP/1.1\r\n"),
+			err:  ErrProxyProtocolHeaderMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := bufio.NewReader(bytes.NewReader(tt.data))
+			src, dst, err := readProxyProtocolHeader(br)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.src == "" {
+				require.Nil(t, src)
+				require.Nil(t, dst)
+			} else {
+				require.Equal(t, tt.src, src.String())
+				require.Equal(t, tt.dst, dst.String())
+			}
+			rest, err := io.ReadAll(br)
+			require.NoError(t, err)
+			require.Equal(t, tt.rest, string(rest))
+		})
+	}
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    ProxyProtocolConfig
+		header string
+		remote string
+		failed bool
+	}{
+		{
+			name:   "optional with header",
+			header: "PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\n",
+			remote: "192.0.2.1:56324",
+		},
+		{
+			name:   "optional without header",
+			remote: "127.0.0.1:",
+		},
+		{
+			name:   "required without header",
+			cfg:    ProxyProtocolConfig{Policy: ProxyProtocolRequired},
+			failed: true,
+		},
+		{
+			name:   "untrusted source header ignored",
+			cfg:    ProxyProtocolConfig{TrustedSources: []string{"10.0.0.0/8"}},
+			header: "PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\n",
+			failed: true,
+		},
+		{
+			name:   "trusted source",
+			cfg:    ProxyProtocolConfig{TrustedSources: []string{"127.0.0.1"}, Policy: ProxyProtocolRequired},
+			header: "PROXY TCP4 192.0.2.1 127.0.0.1 56324 443\r\n",
+			remote: "192.0.2.1:56324",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+
+			pl, err := newProxyProtocolListener(l, tt.cfg)
+			require.NoError(t, err)
+
+			hs := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.RemoteAddr))
+			})}
+			go func() { _ = hs.Serve(pl) }()
+			defer hs.Close()
+
+			c, err := net.Dial("tcp", l.Addr().String())
+			require.NoError(t, err)
+			defer c.Close()
+			require.NoError(t, c.SetDeadline(time.Now().Add(5*time.Second)))
+
+			_, err = c.Write([]byte(tt.header + "GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+			require.NoError(t, err)
+
+			rsp, err := http.ReadResponse(bufio.NewReader(c), nil)
+			if tt.failed {
+				if err == nil {
+					require.NotEqual(t, http.StatusOK, rsp.StatusCode)
+				}
+				return
+			}
+			require.NoError(t, err)
+			defer rsp.Body.Close()
+			buf, err := io.ReadAll(rsp.Body)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(string(buf), tt.remote), "remote addr %s", buf)
+		})
+	}
+}
+
+func TestProxyProtocolListenerInvalidTrustedSource(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	_, err = newProxyProtocolListener(l, ProxyProtocolConfig{TrustedSources: []string{"invalid"}})
+	require.Error(t, err)
+}
@@ -0,0 +1,321 @@
+package http
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var typeOfProtoMessage = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// errUnknownQueryParam returned when query parameter not matches any message field
+var errUnknownQueryParam = fmt.Errorf("unknown query parameter")
+
+// bindQuery fills proto message fields from query parameters like grpc-gateway,
+// parameter name is dot separated path of proto or json field names, map entries passed as name[key],
+// repeated fields filled by repeated parameters, for repeated messages n-th value goes to n-th element.
+// Unknown parameters ignored, or returned as error in strict mode, reserved parameters and parameters
+// of fields filled from request body, * for whole message, skipped.
+func bindQuery(msg proto.Message, query string, strict bool, body string, reserved ...string) error {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m := msg.ProtoReflect()
+
+	var unknown []string
+	for _, k := range keys {
+		if slices.Contains(reserved, k) || coveredByBody(m.Descriptor(), body, k) {
+			continue
+		}
+		if err := setQueryField(m, k, values[k]); err == errUnknownQueryParam {
+			unknown = append(unknown, k)
+		} else if err != nil {
+			return fmt.Errorf("invalid query parameter %s: %w", k, err)
+		}
+	}
+
+	if strict && len(unknown) > 0 {
+		return fmt.Errorf("unknown query parameters: %s", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// bindPath fills proto message fields from path parameters, parameters not matching fields ignored
+func bindPath(msg proto.Message, params map[string]string) error {
+	m := msg.ProtoReflect()
+	for k, v := range params {
+		if err := setQueryField(m, k, []string{v}); err != nil && err != errUnknownQueryParam {
+			return fmt.Errorf("invalid path parameter %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// coveredByBody reports whether query parameter names field filled from request body
+func coveredByBody(md protoreflect.MessageDescriptor, body, param string) bool {
+	switch body {
+	case "":
+		return false
+	case "*":
+		return true
+	}
+	name, _, _ := strings.Cut(param, ".")
+	if idx := strings.IndexByte(name, '['); idx > 0 {
+		name = name[:idx]
+	}
+	fd := queryField(md, name)
+	return fd != nil && fd == queryField(md, body)
+}
+
+// queryField returns message field by proto or json name
+func queryField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return fields.ByJSONName(name)
+}
+
+// checkOneof returns error if other field of the same oneof already set
+func checkOneof(m protoreflect.Message, fd protoreflect.FieldDescriptor) error {
+	od := fd.ContainingOneof()
+	if od == nil || od.IsSynthetic() {
+		return nil
+	}
+	if set := m.WhichOneof(od); set != nil && set != fd {
+		return fmt.Errorf("field %s of oneof %s already set", set.Name(), od.Name())
+	}
+	return nil
+}
+
+func setQueryField(m protoreflect.Message, path string, vals []string) error {
+	name, rest, nested := strings.Cut(path, ".")
+
+	var mapKey string
+	if idx := strings.IndexByte(name, '['); idx > 0 && strings.HasSuffix(name, "]") {
+		name, mapKey = name[:idx], name[idx+1:len(name)-1]
+	}
+
+	fd := queryField(m.Descriptor(), name)
+	if fd == nil {
+		return errUnknownQueryParam
+	}
+
+	switch {
+	case fd.IsMap():
+		if nested || mapKey == "" {
+			return errUnknownQueryParam
+		}
+		return setQueryMapEntry(m, fd, mapKey, vals)
+	case mapKey != "":
+		return errUnknownQueryParam
+	case nested:
+		if fd.Message() == nil {
+			return errUnknownQueryParam
+		}
+		if fd.IsList() {
+			list := m.Mutable(fd).List()
+			for idx, val := range vals {
+				for list.Len() <= idx {
+					list.Append(list.NewElement())
+				}
+				if err := setQueryField(list.Get(idx).Message(), rest, []string{val}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := checkOneof(m, fd); err != nil {
+			return err
+		}
+		return setQueryField(m.Mutable(fd).Message(), rest, vals)
+	case fd.IsList():
+		list := m.Mutable(fd).List()
+		for _, val := range vals {
+			v, err := parseQueryValue(fd, list.NewElement, val)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+		return nil
+	}
+
+	if len(vals) > 1 {
+		return fmt.Errorf("too many values for not repeated field %s", fd.Name())
+	}
+	if err := checkOneof(m, fd); err != nil {
+		return err
+	}
+
+	v, err := parseQueryValue(fd, func() protoreflect.Value { return m.NewField(fd) }, vals[0])
+	if err != nil {
+		return err
+	}
+	m.Set(fd, v)
+
+	return nil
+}
+
+func setQueryMapEntry(m protoreflect.Message, fd protoreflect.FieldDescriptor, key string, vals []string) error {
+	if len(vals) > 1 {
+		return fmt.Errorf("too many values for map entry %s[%s]", fd.Name(), key)
+	}
+
+	k, err := parseQueryValue(fd.MapKey(), nil, key)
+	if err != nil {
+		return err
+	}
+
+	mp := m.Mutable(fd).Map()
+	v, err := parseQueryValue(fd.MapValue(), mp.NewValue, vals[0])
+	if err != nil {
+		return err
+	}
+	mp.Set(k.MapKey(), v)
+
+	return nil
+}
+
+// parseQueryValue converts query value to field value, newMessage used for message fields
+func parseQueryValue(fd protoreflect.FieldDescriptor, newMessage func() protoreflect.Value, val string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(val)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(val, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(val, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(val, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(val, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		n, err := strconv.ParseFloat(val, 32)
+		return protoreflect.ValueOfFloat32(float32(n)), err
+	case protoreflect.DoubleKind:
+		n, err := strconv.ParseFloat(val, 64)
+		return protoreflect.ValueOfFloat64(n), err
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(val), nil
+	case protoreflect.BytesKind:
+		buf, err := base64.StdEncoding.DecodeString(val)
+		if err != nil {
+			buf, err = base64.URLEncoding.DecodeString(val)
+		}
+		return protoreflect.ValueOfBytes(buf), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(val)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(val, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown value %s of enum %s", val, fd.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if newMessage == nil {
+			break
+		}
+		v := newMessage()
+		if err := setWellKnown(v.Message(), val); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return v, nil
+	}
+
+	return protoreflect.Value{}, fmt.Errorf("unsupported field type %s", fd.Kind())
+}
+
+// setWellKnown fills well known message from its json string form
+func setWellKnown(m protoreflect.Message, val string) error {
+	md := m.Descriptor()
+	fields := md.Fields()
+
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		ts, err := time.Parse(time.RFC3339Nano, val)
+		if err != nil {
+			return err
+		}
+		m.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(ts.Unix()))
+		m.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(ts.Nanosecond())))
+	case "google.protobuf.Duration":
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return err
+		}
+		m.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(int64(d/time.Second)))
+		m.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(d%time.Second)))
+	case "google.protobuf.FieldMask":
+		list := m.Mutable(fields.ByName("paths")).List()
+		for _, p := range strings.Split(val, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				list.Append(protoreflect.ValueOfString(camelToSnake(p)))
+			}
+		}
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		fd := fields.ByName("value")
+		v, err := parseQueryValue(fd, nil, val)
+		if err != nil {
+			return err
+		}
+		m.Set(fd, v)
+	default:
+		return fmt.Errorf("unsupported message type %s", md.FullName())
+	}
+
+	return nil
+}
+
+// camelToSnake converts json field mask path like fooBar.bazQux to foo_bar.baz_qux
+func camelToSnake(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isProtoMessage reports whether endpoint argument type is proto message
+func isProtoMessage(t reflect.Type) bool {
+	return t.Implements(typeOfProtoMessage)
+}
+
+// reservedQueryParams returns query parameters used by server itself and not bound to request
+func (h *Server) reservedQueryParams() []string {
+	if h.partialResponse {
+		return []string{fieldsQueryParam}
+	}
+	return nil
+}
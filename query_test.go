@@ -0,0 +1,210 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/typepb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func queryTestMessage(t *testing.T) protoreflect.MessageDescriptor {
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(num),
+			Type:   typ.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		if repeated {
+			fd.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		}
+		return fd
+	}
+	msg := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING
+
+	byName := field("by_name", 7, str, "", false)
+	byName.OneofIndex = proto.Int32(0)
+	byID := field("by_id", 8, descriptorpb.FieldDescriptorProto_TYPE_INT32, "", false)
+	byID.OneofIndex = proto.Int32(0)
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("query_test.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		Dependency: []string{
+			"google/protobuf/timestamp.proto", "google/protobuf/duration.proto",
+			"google/protobuf/field_mask.proto", "google/protobuf/wrappers.proto",
+		},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Item"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, str, "", false),
+					field("id", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, "", false),
+				},
+			},
+			{
+				Name: proto.String("Request"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("kind", 1, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".test.Request.Kind", false),
+					field("created_at", 2, msg, ".google.protobuf.Timestamp", false),
+					field("ttl", 3, msg, ".google.protobuf.Duration", false),
+					field("mask", 4, msg, ".google.protobuf.FieldMask", false),
+					field("title", 5, msg, ".google.protobuf.StringValue", false),
+					field("limit", 6, msg, ".google.protobuf.Int64Value", false),
+					byName,
+					byID,
+					field("items", 9, msg, ".test.Item", true),
+					field("tags", 10, str, "", true),
+					field("parent", 11, msg, ".test.Item", false),
+					field("labels", 12, msg, ".test.Request.LabelsEntry", true),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("LabelsEntry"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							field("key", 1, str, "", false),
+							field("value", 2, str, "", false),
+						},
+						Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+					},
+				},
+				EnumType: []*descriptorpb.EnumDescriptorProto{
+					{
+						Name: proto.String("Kind"),
+						Value: []*descriptorpb.EnumValueDescriptorProto{
+							{Name: proto.String("KIND_UNSPECIFIED"), Number: proto.Int32(0)},
+							{Name: proto.String("KIND_BOOK"), Number: proto.Int32(1)},
+							{Name: proto.String("KIND_FILM"), Number: proto.Int32(2)},
+						},
+					},
+				},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("filter")}},
+			},
+		},
+	}
+
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	return fd.Messages().ByName("Request")
+}
+
+func TestBindQuery(t *testing.T) {
+	md := queryTestMessage(t)
+
+	msg := dynamicpb.NewMessage(md)
+	require.NoError(t, bindQuery(msg, "kind=KIND_BOOK&createdAt=2024-01-02T03:04:05.5Z&ttl=1.5s"+
+		"&mask=parent.name,createdAt&title=abc&limit=10&by_name=x&items.name=a&items.name=b&items.id=1"+
+		"&tags=t1&tags=t2&parent.id=7&labels[env]=prod&unknown=1", false, ""))
+
+	buf, err := protojson.Marshal(msg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"kind": "KIND_BOOK",
+		"createdAt": "2024-01-02T03:04:05.500Z",
+		"ttl": "1.500s",
+		"mask": "parent.name,createdAt",
+		"title": "abc",
+		"limit": "10",
+		"byName": "x",
+		"items": [{"name": "a", "id": 1}, {"name": "b"}],
+		"tags": ["t1", "t2"],
+		"parent": {"id": 7},
+		"labels": {"env": "prod"}
+	}`, string(buf))
+
+	// enum by number
+	msg = dynamicpb.NewMessage(md)
+	require.NoError(t, bindQuery(msg, "kind=2", true, ""))
+	require.Equal(t, protoreflect.EnumNumber(2), msg.Get(md.Fields().ByName("kind")).Enum())
+
+	// reserved parameters not bound and not reported as unknown
+	require.NoError(t, bindQuery(dynamicpb.NewMessage(md), "fields=kind&kind=1", true, "", fieldsQueryParam))
+
+	// fields filled from body not bound
+	msg = dynamicpb.NewMessage(md)
+	require.NoError(t, bindQuery(msg, "parent.name=a&kind=1&title=t", true, "parent"))
+	buf, err = protojson.Marshal(msg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"kind":"KIND_BOOK","title":"t"}`, string(buf))
+
+	msg = dynamicpb.NewMessage(md)
+	require.NoError(t, bindQuery(msg, "kind=1&bogus=1", true, "*"))
+	require.Equal(t, protoreflect.EnumNumber(0), msg.Get(md.Fields().ByName("kind")).Enum())
+
+	// path parameters converted like query
+	msg = dynamicpb.NewMessage(md)
+	require.NoError(t, bindPath(msg, map[string]string{"createdAt": "2024-01-02T03:04:05Z", "parent.id": "7", "other": "x"}))
+	buf, err = protojson.Marshal(msg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"createdAt":"2024-01-02T03:04:05Z","parent":{"id":7}}`, string(buf))
+	require.ErrorContains(t, bindPath(dynamicpb.NewMessage(md), map[string]string{"ttl": "forever"}), "invalid path parameter ttl")
+
+	for query, errText := range map[string]string{
+		"by_name=x&by_id=1":        "oneof filter already set",
+		"kind=KIND_TOY":            "unknown value KIND_TOY",
+		"ttl=forever":              "invalid query parameter ttl",
+		"title=a&title=b":          "too many values",
+		"unknown=1&parent.other=2": "unknown query parameters: parent.other, unknown",
+		"kind=%zz":                 "invalid URL escape",
+	} {
+		err := bindQuery(dynamicpb.NewMessage(md), query, true, "")
+		require.ErrorContains(t, err, errText, query)
+	}
+}
+
+type QueryTestHandler struct{}
+
+func (*QueryTestHandler) Get(ctx context.Context, req *typepb.Field, rsp *EchoResponse) error {
+	rsp.Name = fmt.Sprintf("%s %s %d %s", req.Name, req.Kind, req.Number, req.Options[1].Name)
+	return nil
+}
+
+func TestServerBindQuery(t *testing.T) {
+	srv := newTestServer(t, StrictQuery(true))
+	require.NoError(t, srv.Handle(srv.NewHandler(&QueryTestHandler{}, HandlerEndpoints([]EndpointMetadata{
+		{Name: "QueryTest.Get", Method: http.MethodGet, Path: "/v1/fields"},
+	}))))
+	require.NoError(t, srv.Handle(srv.NewHandler(&BindProtoTestHandler{}, HandlerEndpoints([]EndpointMetadata{
+		{Name: "BindProtoTest.Get", Method: http.MethodPost, Path: "/v1/fields/{number}", Body: "*"},
+	}))))
+	c := startTestServer(t, srv)
+
+	code, body := c.get("/v1/fields?name=id&kind=TYPE_STRING&number=3&options.name=a&options.name=b")
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"name":"id TYPE_STRING 3 b"}`, body)
+
+	code, body = c.get("/v1/fields?name=id&bogus=1&extra=2")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, "unknown query parameters: bogus, extra", body)
+
+	// path parameter overrides body, query not overrides body
+	rsp, body := c.do(c.request(http.MethodPost, "/v1/fields/5?name=q&typeUrl=u", strings.NewReader(`{"name":"b","number":3}`)))
+	require.Equal(t, http.StatusOK, rsp.StatusCode, body)
+	require.JSONEq(t, `{"name":"b 5 "}`, body)
+
+	code, _ = c.get("/v1/fields/five")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+	rsp, body = c.do(c.request(http.MethodPost, "/v1/fields/five", nil))
+	require.Equal(t, http.StatusBadRequest, rsp.StatusCode)
+	require.Contains(t, body, "invalid path parameter number")
+}
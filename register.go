@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"go.unistack.org/micro/v4/logger"
+	"go.unistack.org/micro/v4/server"
+)
+
+var (
+	// DefaultRegisterRetryInterval specifies first retry delay after failed registration,
+	// it doubles on each failure up to RegisterInterval
+	DefaultRegisterRetryInterval = time.Second
+
+	// DefaultRegisterJitter specifies max part of registration delay randomly added or subtracted
+	DefaultRegisterJitter = 0.1
+
+	// ServerRegisterTotal specifies meter metric name for registration attempts
+	ServerRegisterTotal = "micro_server_register_total"
+
+	// ServerRegistered specifies meter metric name for registration state
+	ServerRegistered = "micro_server_registered"
+)
+
+// Registered returns true if server registered in register
+func (h *Server) Registered() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.registered
+}
+
+// checkHealth checks server health, register check and user health checks
+func (h *Server) checkHealth(config server.Options) error {
+	if !h.Health() {
+		return fmt.Errorf("server not healthy")
+	}
+
+	if err := config.RegisterCheck(config.Context); err != nil {
+		return err
+	}
+
+	return h.CheckHealth(config.Context)
+}
+
+// CheckHealth runs checks passed via HealthChecks option
+func (h *Server) CheckHealth(ctx context.Context) error {
+	h.mu.RLock()
+	fns, _ := h.opts.Context.Value(healthChecksKey{}).([]HealthCheckFunc)
+	h.mu.RUnlock()
+
+	for _, fn := range fns {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// registerLoop keeps server registered while it healthy, deregisters it on failed health checks
+type registerLoop struct {
+	h        *Server
+	config   server.Options
+	failures int
+}
+
+// tick runs one registration iteration and returns delay till next one
+func (l *registerLoop) tick() time.Duration {
+	config := l.config
+
+	if err := l.h.checkHealth(config); err != nil {
+		if config.Logger.V(logger.ErrorLevel) {
+			config.Logger.Error(config.Context, fmt.Sprintf("Server %s-%s health check error", config.Name, config.ID), err)
+		}
+		if l.h.Registered() {
+			if config.Logger.V(logger.InfoLevel) {
+				config.Logger.Info(config.Context, fmt.Sprintf("Server %s-%s not healthy, deregister it", config.Name, config.ID))
+			}
+			if err := l.h.Deregister(); err != nil && config.Logger.V(logger.ErrorLevel) {
+				config.Logger.Error(config.Context, fmt.Sprintf("Server %s-%s deregister error", config.Name, config.ID), err)
+			}
+		}
+		return jitter(config.RegisterInterval)
+	}
+
+	if err := l.h.Register(); err != nil {
+		config.Meter.Counter(ServerRegisterTotal, "server", "http", "status", "failure").Inc()
+		if config.Logger.V(logger.ErrorLevel) {
+			config.Logger.Error(config.Context, fmt.Sprintf("Server %s-%s register error", config.Name, config.ID), err)
+		}
+		l.failures++
+		return jitter(backoff(l.failures, config.RegisterInterval))
+	}
+
+	config.Meter.Counter(ServerRegisterTotal, "server", "http", "status", "success").Inc()
+	l.failures = 0
+
+	return jitter(config.RegisterInterval)
+}
+
+// backoff returns exponential retry delay limited by max
+func backoff(failures int, max time.Duration) time.Duration {
+	d := DefaultRegisterRetryInterval
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
+
+// jitter randomly changes delay within DefaultRegisterJitter part
+func jitter(d time.Duration) time.Duration {
+	delta := int64(float64(d) * DefaultRegisterJitter)
+	if delta <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int64N(2*delta+1)-delta)
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/register"
+	"go.unistack.org/micro/v4/server"
+)
+
+type memoryRegister = register.Register
+
+// countRegister counts calls and fails registration on demand
+type countRegister struct {
+	memoryRegister
+	registers   atomic.Int32
+	deregisters atomic.Int32
+	fail        atomic.Bool
+}
+
+func (r *countRegister) Register(ctx context.Context, svc *register.Service, opts ...register.RegisterOption) error {
+	r.registers.Add(1)
+	if r.fail.Load() {
+		return errors.New("register unavailable")
+	}
+	return r.memoryRegister.Register(ctx, svc, opts...)
+}
+
+func (r *countRegister) Deregister(ctx context.Context, svc *register.Service, opts ...register.DeregisterOption) error {
+	r.deregisters.Add(1)
+	return r.memoryRegister.Deregister(ctx, svc, opts...)
+}
+
+func TestRegisterBackoff(t *testing.T) {
+	require.Equal(t, time.Second, backoff(1, 10*time.Second))
+	require.Equal(t, 2*time.Second, backoff(2, 10*time.Second))
+	require.Equal(t, 8*time.Second, backoff(4, 10*time.Second))
+	require.Equal(t, 10*time.Second, backoff(5, 10*time.Second))
+	require.Equal(t, 10*time.Second, backoff(100, 10*time.Second))
+
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second)
+		require.GreaterOrEqual(t, d, 900*time.Millisecond)
+		require.LessOrEqual(t, d, 1100*time.Millisecond)
+	}
+}
+
+func TestServerRegisterHealthGated(t *testing.T) {
+	reg := &countRegister{memoryRegister: register.NewRegister()}
+
+	var unhealthy atomic.Bool
+	srv := NewServer(
+		server.Name("svc"),
+		server.Address("127.0.0.1:0"),
+		server.Register(reg),
+		server.RegisterInterval(10*time.Millisecond),
+		HealthChecks(func(context.Context) error {
+			if unhealthy.Load() {
+				return errors.New("db down")
+			}
+			return nil
+		}),
+	)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+	require.True(t, srv.Registered())
+
+	lookup := func() bool {
+		svcs, err := reg.LookupService(context.Background(), "svc")
+		return err == nil && len(svcs) > 0
+	}
+	require.True(t, lookup())
+
+	unhealthy.Store(true)
+	require.Eventually(t, func() bool { return !srv.Registered() && !lookup() }, time.Second, 5*time.Millisecond)
+	deregisters := reg.deregisters.Load()
+	require.Equal(t, int32(1), deregisters)
+
+	unhealthy.Store(false)
+	require.Eventually(t, func() bool { return srv.Registered() && lookup() }, time.Second, 5*time.Millisecond)
+
+	// failed registration retried with exponential backoff
+	reg.fail.Store(true)
+	config := srv.Options()
+	config.RegisterInterval = time.Minute
+	rl := &registerLoop{h: srv, config: config}
+	for _, d := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		td := rl.tick()
+		require.InDelta(t, float64(d), float64(td), float64(d)*DefaultRegisterJitter)
+	}
+	reg.fail.Store(false)
+	require.Equal(t, time.Minute, rl.tick().Round(time.Minute))
+	require.Equal(t, 0, rl.failures)
+
+	deregisters = reg.deregisters.Load()
+	require.NoError(t, srv.Stop())
+	require.Equal(t, deregisters+1, reg.deregisters.Load())
+	require.False(t, lookup())
+}
+
+func TestServerRegisterInitialHealthGated(t *testing.T) {
+	reg := &countRegister{memoryRegister: register.NewRegister()}
+
+	srv := NewServer(
+		server.Name("svc"),
+		server.Address("127.0.0.1:0"),
+		server.Register(reg),
+		HealthChecks(func(context.Context) error {
+			return errors.New("db down")
+		}),
+	)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+	require.False(t, srv.Registered())
+	require.Equal(t, int32(0), reg.registers.Load())
+	require.Error(t, srv.CheckHealth(context.Background()))
+	require.NoError(t, srv.Stop())
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"go.unistack.org/micro/v4/metadata"
+)
+
+// RequestInfo holds properties of http request served by handler
+type RequestInfo struct {
+	// TLS contains connection state, nil for plain http
+	TLS *tls.ConnectionState
+	// URL is request url before base path stripped
+	URL *url.URL
+	// BasePath is server base path, empty for root
+	BasePath string
+	// PathParams contains values of path template parameters
+	PathParams map[string]string
+	Method     string
+	// Route is matched path template, empty if no route matched
+	Route      string
+	RemoteAddr string
+	// Scheme is http or https
+	Scheme     string
+	Proto      string
+	Host       string
+	RequestURI string
+	// TransferEncoding lists transfer encodings from outermost to innermost
+	TransferEncoding []string
+	ContentLength    int64
+}
+
+type requestInfoKey struct{}
+
+// RequestInfoFromContext returns info about http request served by handler
+func RequestInfoFromContext(ctx context.Context) (*RequestInfo, bool) {
+	ri, ok := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return ri, ok
+}
+
+func newRequestInfoContext(ctx context.Context, ri *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, ri)
+}
+
+func newRequestInfo(r *http.Request) *RequestInfo {
+	ri := &RequestInfo{
+		TLS:              r.TLS,
+		URL:              r.URL,
+		Method:           r.Method,
+		RemoteAddr:       r.RemoteAddr,
+		Scheme:           "http",
+		Proto:            r.Proto,
+		Host:             r.Host,
+		RequestURI:       r.RequestURI,
+		TransferEncoding: r.TransferEncoding,
+		ContentLength:    r.ContentLength,
+	}
+	if r.TLS != nil {
+		ri.Scheme = "https"
+	}
+	return ri
+}
+
+// legacyMetadata returns request properties as pseudo headers injected in incoming metadata
+// by previous versions, they replace headers with the same names sent by client
+func (ri *RequestInfo) legacyMetadata() metadata.Metadata {
+	md := metadata.Metadata{
+		"RemoteAddr":     {ri.RemoteAddr},
+		"Scheme":         {ri.Scheme},
+		"Method":         {ri.Method},
+		"URL":            {ri.URL.String()},
+		"Proto":          {ri.Proto},
+		"Content-Length": {strconv.FormatInt(ri.ContentLength, 10)},
+		"Host":           {ri.Host},
+		"RequestURI":     {ri.RequestURI},
+	}
+	if len(ri.TransferEncoding) > 0 {
+		md["Transfer-Encoding"] = ri.TransferEncoding
+	}
+	if ri.TLS != nil {
+		md["TLS"] = []string{"true"}
+		md["TLS-ALPN"] = []string{ri.TLS.NegotiatedProtocol}
+		md["TLS-ServerName"] = []string{ri.TLS.ServerName}
+	}
+	return md
+}
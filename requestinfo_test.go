@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/metadata"
+)
+
+type InfoTestHandler struct{}
+
+func (*InfoTestHandler) Get(ctx context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	info, ok := RequestInfoFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	rsp.Name = strings.Join([]string{
+		info.Method, info.Route, info.PathParams["name"], info.Scheme, info.URL.Path, strings.Join(md.Get("Method"), ","),
+	}, " ")
+	return nil
+}
+
+func TestServerRequestInfo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		legacy   bool
+		expected string
+	}{
+		// client header passed as is
+		{name: "default", expected: `{"name":"GET /v1/{name} abc http /api/v1/abc DELETE"}`},
+		// client header replaced
+		{name: "legacy", legacy: true, expected: `{"name":"GET /v1/{name} abc http /api/v1/abc GET"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer(t,
+				BasePath("/api"),
+				LegacyRequestMetadata(tc.legacy),
+				PathHandler(http.MethodGet, "/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+					info, ok := RequestInfoFromContext(r.Context())
+					require.True(t, ok)
+					_, _ = w.Write([]byte(info.BasePath + " " + info.Route + " " + info.PathParams["id"]))
+				}),
+			)
+			require.NoError(t, srv.Handle(srv.NewHandler(&InfoTestHandler{}, HandlerEndpoints([]EndpointMetadata{
+				{Name: "InfoTest.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+			}))))
+			c := startTestServer(t, srv)
+
+			get := func(path string) string {
+				req := c.request(http.MethodGet, path, nil)
+				// spoofed pseudo header
+				req.Header.Set("Method", "DELETE")
+				_, body := c.do(req)
+				return body
+			}
+
+			require.JSONEq(t, tc.expected, get("/api/v1/abc"))
+			require.Equal(t, "/api /items/{id} 1", get("/api/items/1"))
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package http
 
 import (
+	"bufio"
 	"context"
+	"net"
 	"net/http"
 )
 
@@ -27,3 +29,98 @@ func GetResponseStatusCode(ctx context.Context) int {
 	}
 	return code
 }
+
+type (
+	rawRequestKey     struct{}
+	rawResponseKey    struct{}
+	rawResponseWriter struct {
+		http.ResponseWriter
+		md       *rspMetadataVal
+		status   int
+		hijacked bool
+	}
+)
+
+// RequestFromContext returns http request served by endpoint handler
+func RequestFromContext(ctx context.Context) (*http.Request, bool) {
+	r, ok := ctx.Value(rawRequestKey{}).(*http.Request)
+	return r, ok
+}
+
+// ResponseWriterFromContext returns http response writer of endpoint handler,
+// if handler writes to it, server not writes reply
+func ResponseWriterFromContext(ctx context.Context) (http.ResponseWriter, bool) {
+	w, ok := ctx.Value(rawResponseKey{}).(*rawResponseWriter)
+	return w, ok
+}
+
+// HijackResponse tells server that endpoint handler writes response itself via ResponseWriterFromContext,
+// so reply not marshaled and response metadata not written even if handler writes nothing
+func HijackResponse(ctx context.Context) {
+	if w, ok := ctx.Value(rawResponseKey{}).(*rawResponseWriter); ok {
+		w.hijacked = true
+	}
+}
+
+func newRawResponseWriter(ctx context.Context, w http.ResponseWriter) *rawResponseWriter {
+	md, _ := ctx.Value(rspMetadataKey{}).(*rspMetadataVal)
+	return &rawResponseWriter{ResponseWriter: w, md: md}
+}
+
+func newRawContext(ctx context.Context, r *http.Request, w *rawResponseWriter) context.Context {
+	ctx = context.WithValue(ctx, rawRequestKey{}, r)
+	return context.WithValue(ctx, rawResponseKey{}, w)
+}
+
+func (w *rawResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		if w.md != nil {
+			w.md.writeHeader(w.Header())
+			w.md.declareTrailers(w.Header())
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *rawResponseWriter) Write(buf []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(buf)
+}
+
+func (w *rawResponseWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack lets endpoint handler take over connection, response treated as written by handler
+func (w *rawResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.hijacked = true
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, nil
+}
+
+// Unwrap used by http.ResponseController
+func (w *rawResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// written reports whether endpoint handler wrote response itself or hijacked it,
+// error returned by handler written by server only if nothing written
+func (w *rawResponseWriter) written(ctx context.Context, appErr error) bool {
+	if w.status != 0 {
+		SetResponseStatusCode(ctx, w.status)
+		return true
+	}
+	return w.hijacked && appErr == nil
+}
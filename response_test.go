@@ -2,10 +2,12 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/metadata"
 )
 
 func TestSetResponseStatusCode(t *testing.T) {
@@ -77,3 +79,85 @@ func TestGetResponseStatusCode(t *testing.T) {
 		})
 	}
 }
+
+type RawTestHandler struct{}
+
+func (*RawTestHandler) Download(ctx context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	w, ok := ResponseWriterFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("no response writer")
+	}
+	r, ok := RequestFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("no request")
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusCreated)
+	_, err := w.Write([]byte("file " + req.Name + " " + r.Method))
+	return err
+}
+
+func (*RawTestHandler) Redirect(ctx context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	HijackResponse(ctx)
+	AppendResponseMetadata(ctx, metadata.Metadata{"Set-Cookie": []string{"session=" + req.Name}})
+	w, _ := ResponseWriterFromContext(ctx)
+	r, _ := RequestFromContext(ctx)
+	http.Redirect(w, r, "/files/"+req.Name, http.StatusFound)
+	return nil
+}
+
+func (*RawTestHandler) Upgrade(ctx context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	w, _ := ResponseWriterFromContext(ctx)
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, _ = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok")
+	if err = rw.Flush(); err != nil {
+		return err
+	}
+	// error after hijack not written by server
+	return fmt.Errorf("connection closed")
+}
+
+func (*RawTestHandler) Get(ctx context.Context, req *EchoRequest, rsp *EchoResponse) error {
+	rsp.Name = req.Name
+	return nil
+}
+
+func TestServerRawResponse(t *testing.T) {
+	srv := newTestServer(t)
+	require.NoError(t, srv.Handle(srv.NewHandler(&RawTestHandler{}, HandlerEndpoints([]EndpointMetadata{
+		{Name: "RawTest.Download", Method: http.MethodGet, Path: "/files/{name}"},
+		{Name: "RawTest.Redirect", Method: http.MethodGet, Path: "/redirect/{name}"},
+		{Name: "RawTest.Get", Method: http.MethodGet, Path: "/get/{name}"},
+		{Name: "RawTest.Upgrade", Method: http.MethodGet, Path: "/upgrade/{name}"},
+	}))))
+	c := startTestServer(t, srv)
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	get := func(path string) (*http.Response, string) {
+		return c.do(c.request(http.MethodGet, path, nil))
+	}
+
+	rsp, body := get("/files/a")
+	require.Equal(t, http.StatusCreated, rsp.StatusCode)
+	require.Equal(t, "text/plain", rsp.Header.Get("Content-Type"))
+	require.Equal(t, "file a GET", body)
+
+	rsp, _ = get("/redirect/a")
+	require.Equal(t, http.StatusFound, rsp.StatusCode)
+	require.Equal(t, "/files/a", rsp.Header.Get("Location"))
+	require.Equal(t, "session=a", rsp.Header.Get("Set-Cookie"))
+
+	rsp, body = get("/upgrade/a")
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.Equal(t, "ok", body)
+
+	rsp, body = get("/get/a")
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.JSONEq(t, `{"name":"a"}`, body)
+}
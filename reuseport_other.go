@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package http
+
+import (
+	"syscall"
+)
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return ErrReusePortNotSupported
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package http
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
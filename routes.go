@@ -0,0 +1,450 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+
+	"go.unistack.org/micro/v4/metadata"
+	"go.unistack.org/micro/v4/server"
+	rhttp "go.unistack.org/micro/v4/util/http"
+)
+
+// ErrRouteNotFound returned when removed handler or path handler not exists
+var ErrRouteNotFound = errors.New("route not found")
+
+// MetadataRoutes is register node metadata key with json encoded server routes
+var MetadataRoutes = "http-routes"
+
+// Route describes http route served by server
+type Route struct {
+	// Name is endpoint name like Service.Method, empty for path handlers
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Host is host or wildcard subdomain route bound to, empty for any host
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+	// Method is http method
+	Method string `json:"method" yaml:"method"`
+	// Path is path template
+	Path string `json:"path" yaml:"path"`
+	// Body is request field mapped to http body, * for whole request
+	Body string `json:"body,omitempty" yaml:"body,omitempty"`
+	// Handler is handler type or func name, filled only by Server.Routes
+	Handler string `json:"handler,omitempty" yaml:"handler,omitempty"`
+	// Middlewares applied to route, filled only by Server.Routes
+	Middlewares []string `json:"middlewares,omitempty" yaml:"middlewares,omitempty"`
+	// Stream is true for streaming endpoints
+	Stream bool `json:"stream,omitempty" yaml:"stream,omitempty"`
+}
+
+// RoutesFromMetadata returns routes published by server in register node metadata
+func RoutesFromMetadata(md metadata.Metadata) ([]Route, error) {
+	v, ok := md[MetadataRoutes]
+	if !ok || len(v) == 0 {
+		return nil, nil
+	}
+
+	var routes []Route
+	if err := json.Unmarshal([]byte(v[0]), &routes); err != nil {
+		return nil, err
+	}
+
+	return routes, nil
+}
+
+// routesMetadata encodes routes for register node metadata
+func routesMetadata(routes []Route) (string, error) {
+	if len(routes) == 0 {
+		return "", nil
+	}
+
+	buf, err := json.Marshal(routes)
+	if err != nil {
+		return "", err
+	}
+
+	return string(buf), nil
+}
+
+// routeTable holds handlers used to serve requests, it never modified after publishing,
+// changes made on copy that atomically replaces current table
+type routeTable struct {
+	handlers map[string]server.Handler
+	// path handlers trie and paths indexed by host, empty host for any host
+	pathHandlers map[string]*rhttp.Trie
+	paths        map[string]map[string]map[string]http.HandlerFunc
+	methods      []string
+	// route templates without params indexed by lower cased path,
+	// templates with params indexed by segments count, used to fix path case
+	foldedPaths  map[string][]string
+	foldedParams map[int][][]string
+	// handlers by host patterns and path handlers hosts ordered by pattern rank,
+	// so request host only checked against them
+	hostHandlers []hostHandler
+	pathHosts    []string
+}
+
+// hostHandler is handler with one of its host patterns, empty for handler not bound to host
+type hostHandler struct {
+	handler *httpHandler
+	pattern string
+}
+
+// serves reports whether handler serves host by pattern, handler served by most specific
+// of its patterns only, so it searched once
+func (hh hostHandler) serves(host string) bool {
+	if hh.pattern == "" {
+		return true
+	}
+	match, rank := matchHost(hh.handler.hosts, host)
+	return rank > 0 && match == hh.pattern
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{
+		handlers:     make(map[string]server.Handler),
+		pathHandlers: make(map[string]*rhttp.Trie),
+		paths:        make(map[string]map[string]map[string]http.HandlerFunc),
+	}
+}
+
+// clone returns copy of table, path handlers tries shared till rebuild
+func (rt *routeTable) clone() *routeTable {
+	nrt := &routeTable{
+		handlers:     maps.Clone(rt.handlers),
+		pathHandlers: rt.pathHandlers,
+		paths:        make(map[string]map[string]map[string]http.HandlerFunc, len(rt.paths)),
+	}
+	for host, ms := range rt.paths {
+		nrt.paths[host] = make(map[string]map[string]http.HandlerFunc, len(ms))
+		for method, ps := range ms {
+			nrt.paths[host][method] = maps.Clone(ps)
+		}
+	}
+	return nrt
+}
+
+// rebuild creates new path handlers tries, trie not supports removal, and route indexes
+func (rt *routeTable) rebuild() error {
+	tries := make(map[string]*rhttp.Trie, len(rt.paths))
+	for host, ms := range rt.paths {
+		trie := rhttp.NewTrie()
+		for method, ps := range ms {
+			for path, ph := range ps {
+				pr := &pathRoute{handler: ph, route: Route{Method: method, Path: path, Host: host}}
+				if err := trie.Insert([]string{method}, path, pr); err != nil {
+					return err
+				}
+			}
+		}
+		tries[host] = trie
+	}
+	rt.pathHandlers = tries
+
+	rt.pathHosts = slices.Collect(maps.Keys(tries))
+	sort.Slice(rt.pathHosts, func(i, j int) bool {
+		return patternRank(rt.pathHosts[i]) > patternRank(rt.pathHosts[j])
+	})
+
+	rt.hostHandlers = nil
+	for _, hdlr := range rt.handlers {
+		hh, ok := hdlr.(*httpHandler)
+		if !ok {
+			continue
+		}
+		if len(hh.hosts) == 0 {
+			rt.hostHandlers = append(rt.hostHandlers, hostHandler{handler: hh})
+		}
+		for _, pattern := range hh.hosts {
+			rt.hostHandlers = append(rt.hostHandlers, hostHandler{handler: hh, pattern: pattern})
+		}
+	}
+	sort.SliceStable(rt.hostHandlers, func(i, j int) bool {
+		return patternRank(rt.hostHandlers[i].pattern) > patternRank(rt.hostHandlers[j].pattern)
+	})
+
+	rt.methods = nil
+	rt.foldedPaths = make(map[string][]string)
+	rt.foldedParams = make(map[int][][]string)
+	for _, r := range rt.list(false) {
+		if !slices.Contains(rt.methods, r.Method) {
+			rt.methods = append(rt.methods, r.Method)
+		}
+		if !strings.Contains(r.Path, "{") {
+			lp := strings.ToLower(r.Path)
+			if !slices.Contains(rt.foldedPaths[lp], r.Path) {
+				rt.foldedPaths[lp] = append(rt.foldedPaths[lp], r.Path)
+			}
+			continue
+		}
+		segs := strings.Split(r.Path, "/")
+		if !slices.ContainsFunc(rt.foldedParams[len(segs)], func(v []string) bool { return slices.Equal(v, segs) }) {
+			rt.foldedParams[len(segs)] = append(rt.foldedParams[len(segs)], segs)
+		}
+	}
+
+	return nil
+}
+
+// list returns routes sorted by path, method, host and name, handler names filled if full
+func (rt *routeTable) list(full bool) []Route {
+	var routes []Route
+
+	for _, hdlr := range rt.handlers {
+		hh, ok := hdlr.(*httpHandler)
+		if !ok {
+			continue
+		}
+		hosts := hh.hosts
+		if len(hosts) == 0 {
+			hosts = []string{""}
+		}
+		for _, r := range hh.routes {
+			if full {
+				r.Handler = reflect.Indirect(reflect.ValueOf(hh.hd)).Type().String()
+				for _, mw := range hh.middlewares(r.Name) {
+					r.Middlewares = append(r.Middlewares, funcName(mw))
+				}
+			}
+			for _, host := range hosts {
+				r.Host = host
+				routes = append(routes, r)
+			}
+		}
+	}
+
+	for host, ms := range rt.paths {
+		for method, ps := range ms {
+			for path, ph := range ps {
+				r := Route{Method: method, Path: path, Host: host}
+				if full {
+					r.Handler = funcName(ph)
+				}
+				routes = append(routes, r)
+			}
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+		if routes[i].Host != routes[j].Host {
+			return routes[i].Host < routes[j].Host
+		}
+		return routes[i].Name < routes[j].Name
+	})
+
+	return routes
+}
+
+// searchHandlers searches handlers serving host, handlers bound to exact host searched first,
+// then bound to wildcard subdomains and then not bound to any host
+func (rt *routeTable) searchHandlers(host, method, path string) (*httpHandler, interface{}, map[string]string, error) {
+	err := rhttp.ErrNotFound
+	for _, hh := range rt.hostHandlers {
+		if !hh.serves(host) {
+			continue
+		}
+		fh, mp, serr := hh.handler.handlers.Search(method, path)
+		if serr == nil {
+			return hh.handler, fh, mp, nil
+		} else if serr == rhttp.ErrMethodNotAllowed {
+			err = serr
+		}
+	}
+	return nil, nil, nil, err
+}
+
+// searchPath returns path handler and path params for host, method and path, host specific handlers preferred
+func (rt *routeTable) searchPath(host, method, path string) (*pathRoute, map[string]string, error) {
+	err := rhttp.ErrNotFound
+	for _, pattern := range rt.pathHosts {
+		if pattern != "" && hostRank([]string{pattern}, host) <= 0 {
+			continue
+		}
+		ph, mp, serr := rt.pathHandlers[pattern].Search(method, path)
+		if serr == nil {
+			return ph.(*pathRoute), mp, nil
+		} else if serr == rhttp.ErrMethodNotAllowed {
+			err = serr
+		}
+	}
+	return nil, nil, err
+}
+
+// has reports whether route for host, method and path exists
+func (rt *routeTable) has(host, method, path string) bool {
+	if _, _, _, err := rt.searchHandlers(host, method, path); err == nil {
+		return true
+	}
+	_, _, err := rt.searchPath(host, method, path)
+	return err == nil
+}
+
+// allowed returns methods allowed for host and path, HEAD implied by GET and OPTIONS by any route
+func (rt *routeTable) allowed(host, path string) []string {
+	var methods []string
+	for _, m := range rt.methods {
+		if rt.has(host, m, path) {
+			methods = append(methods, m)
+		}
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	if !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// searchMethod returns method used to search route, HEAD served by GET route if no HEAD one
+func (rt *routeTable) searchMethod(host, method, path string) string {
+	if method == http.MethodHead && !rt.has(host, http.MethodHead, path) && rt.has(host, http.MethodGet, path) {
+		return http.MethodGet
+	}
+	return method
+}
+
+func (rt *routeTable) addPath(host, method, path string, handler http.HandlerFunc) {
+	host = strings.ToLower(host)
+	ms, ok := rt.paths[host]
+	if !ok {
+		ms = make(map[string]map[string]http.HandlerFunc)
+		rt.paths[host] = ms
+	}
+	ps, ok := ms[method]
+	if !ok {
+		ps = make(map[string]http.HandlerFunc)
+		ms[method] = ps
+	}
+	ps[path] = handler
+}
+
+func (rt *routeTable) removePath(host, method, path string) error {
+	host = strings.ToLower(host)
+	if _, ok := rt.paths[host][method][path]; !ok {
+		return ErrRouteNotFound
+	}
+	delete(rt.paths[host][method], path)
+	if len(rt.paths[host][method]) == 0 {
+		delete(rt.paths[host], method)
+	}
+	if len(rt.paths[host]) == 0 {
+		delete(rt.paths, host)
+	}
+	return nil
+}
+
+// funcName returns name of function
+func funcName(fn interface{}) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		return f.Name()
+	}
+	return ""
+}
+
+// Routes returns all routes served by server with handlers and middlewares,
+// server middlewares listed before handler and endpoint ones
+func (h *Server) Routes() []Route {
+	h.mu.RLock()
+	config := h.opts
+	basePath := h.basePath
+	pmws := h.pathMiddlewares
+	h.mu.RUnlock()
+
+	var mws []string
+	if mwf, ok := config.Context.Value(middlewareKey{}).([]func(http.Handler) http.Handler); ok {
+		for _, mw := range mwf {
+			mws = append(mws, funcName(mw))
+		}
+	}
+
+	routes := h.routeTable().list(true)
+	for i := range routes {
+		if routes[i].Name == "" {
+			for _, mw := range pmws[routes[i].Method+" "+routes[i].Path] {
+				routes[i].Middlewares = append(routes[i].Middlewares, funcName(mw))
+			}
+		}
+		routes[i].Path = basePath + routes[i].Path
+		routes[i].Middlewares = append(slices.Clone(mws), routes[i].Middlewares...)
+	}
+
+	return routes
+}
+
+// routeTable returns current route table
+func (h *Server) routeTable() *routeTable {
+	return h.routes.Load()
+}
+
+// updateRoutes applies fn to copy of route table, rebuilds and publishes it,
+// register service rebuilt on next registration
+func (h *Server) updateRoutes(fn func(*routeTable) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rt := h.routes.Load().clone()
+	if err := fn(rt); err != nil {
+		return err
+	}
+	if err := rt.rebuild(); err != nil {
+		return err
+	}
+
+	h.routes.Store(rt)
+	h.rsvc = nil
+
+	return nil
+}
+
+// RemoveHandler removes handler registered via Handle, it can be called while server running
+func (h *Server) RemoveHandler(name string) error {
+	return h.updateRoutes(func(rt *routeTable) error {
+		if _, ok := rt.handlers[name]; !ok {
+			return ErrRouteNotFound
+		}
+		delete(rt.handlers, name)
+		return nil
+	})
+}
+
+// AddPathHandler adds or replaces http handler for method and path, it can be called while server running
+func (h *Server) AddPathHandler(method, path string, handler http.HandlerFunc) error {
+	return h.AddHostPathHandler("", method, path, handler)
+}
+
+// RemovePathHandler removes http handler for method and path, it can be called while server running
+func (h *Server) RemovePathHandler(method, path string) error {
+	return h.RemoveHostPathHandler("", method, path)
+}
+
+// AddHostPathHandler adds or replaces http handler for host, method and path,
+// host can be wildcard subdomain like *.example.com, it can be called while server running
+func (h *Server) AddHostPathHandler(host, method, path string, handler http.HandlerFunc) error {
+	return h.updateRoutes(func(rt *routeTable) error {
+		rt.addPath(host, method, path, handler)
+		return nil
+	})
+}
+
+// RemoveHostPathHandler removes http handler for host, method and path, it can be called while server running
+func (h *Server) RemoveHostPathHandler(host, method, path string) error {
+	return h.updateRoutes(func(rt *routeTable) error {
+		return rt.removePath(host, method, path)
+	})
+}
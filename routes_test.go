@@ -0,0 +1,189 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/register"
+	"go.unistack.org/micro/v4/server"
+)
+
+func TestServerRuntimeRoutes(t *testing.T) {
+	srv := newTestServer(t,
+		server.Register(register.NewRegister()),
+		PathHandler(http.MethodGet, "/static", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("static"))
+		}),
+	)
+	c := startTestServer(t, srv)
+
+	code, _ := c.get("/plugin")
+	require.Equal(t, http.StatusNotFound, code)
+
+	srv.mu.RLock()
+	require.NotNil(t, srv.rsvc)
+	srv.mu.RUnlock()
+
+	require.NoError(t, srv.AddPathHandler(http.MethodGet, "/plugin", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("plugin"))
+	}))
+
+	// register service rebuilt on next registration
+	srv.mu.RLock()
+	require.Nil(t, srv.rsvc)
+	srv.mu.RUnlock()
+
+	code, body := c.get("/plugin")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "plugin", body)
+
+	// concurrent changes while serving, errors checked in test goroutine
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			rsp, err := c.Get(c.url("/static"))
+			if err == nil {
+				var buf []byte
+				buf, err = io.ReadAll(rsp.Body)
+				rsp.Body.Close()
+				if err == nil && (rsp.StatusCode != http.StatusOK || string(buf) != "static") {
+					err = fmt.Errorf("unexpected response %d %s", rsp.StatusCode, buf)
+				}
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			err := srv.AddPathHandler(http.MethodPost, "/tmp", func(http.ResponseWriter, *http.Request) {})
+			_ = srv.RemovePathHandler(http.MethodPost, "/tmp")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, srv.RemovePathHandler(http.MethodGet, "/plugin"))
+	code, _ = c.get("/plugin")
+	require.Equal(t, http.StatusNotFound, code)
+
+	require.ErrorIs(t, srv.RemovePathHandler(http.MethodGet, "/plugin"), ErrRouteNotFound)
+	require.ErrorIs(t, srv.RemoveHandler("Unknown"), ErrRouteNotFound)
+
+	code, body = c.get("/static")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "static", body)
+}
+
+func TestServerRegisterRoutes(t *testing.T) {
+	reg := register.NewRegister()
+	srv := newTestServer(t,
+		server.Name("svc"),
+		server.Register(reg),
+		PathHandler(http.MethodGet, "/static", func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	handleTestEndpoints(t, srv, &EchoHandler{}, []EndpointMetadata{
+		{Name: "Echo.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+		{Name: "Echo.Update", Method: http.MethodPut, Path: "/v1/{name}", Body: "*"},
+	})
+	startTestServer(t, srv)
+
+	routes := func() []Route {
+		svcs, err := reg.LookupService(context.Background(), "svc")
+		require.NoError(t, err)
+		require.Len(t, svcs, 1)
+		require.Len(t, svcs[0].Nodes, 1)
+		routes, err := RoutesFromMetadata(svcs[0].Nodes[0].Metadata)
+		require.NoError(t, err)
+		return routes
+	}
+
+	require.Equal(t, []Route{
+		{Method: http.MethodGet, Path: "/static"},
+		{Name: "Echo.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+		{Name: "Echo.Update", Method: http.MethodPut, Path: "/v1/{name}", Body: "*"},
+	}, routes())
+
+	// runtime changes published on next registration
+	require.NoError(t, srv.AddPathHandler(http.MethodPost, "/plugin", func(w http.ResponseWriter, r *http.Request) {}))
+	require.NoError(t, srv.Register())
+	require.Contains(t, routes(), Route{Method: http.MethodPost, Path: "/plugin"})
+}
+
+func routesTestMiddleware(next http.Handler) http.Handler {
+	return next
+}
+
+func TestServerRoutes(t *testing.T) {
+	srv := newTestServer(t,
+		Middleware(routesTestMiddleware),
+		PathHandler(http.MethodGet, "/static", func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	handleTestEndpoints(t, srv, &EchoHandler{}, []EndpointMetadata{
+		{Name: "Echo.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+	})
+
+	routes := srv.Routes()
+	require.Len(t, routes, 2)
+
+	require.Equal(t, "/static", routes[0].Path)
+	require.Contains(t, routes[0].Handler, "TestServerRoutes")
+	require.Equal(t, []string{"go.unistack.org/micro-server-http/v4.routesTestMiddleware"}, routes[0].Middlewares)
+
+	require.Equal(t, "Echo.Get", routes[1].Name)
+	require.Equal(t, "http.EchoHandler", routes[1].Handler)
+	require.Equal(t, routes[0].Middlewares, routes[1].Middlewares)
+}
+
+func TestServerAllowedMethods(t *testing.T) {
+	items := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("items"))
+	}
+	srv := newTestServer(t,
+		PathHandler(http.MethodGet, "/items", items),
+		PathHandler(http.MethodPost, "/items", items),
+		PathHandler(http.MethodDelete, "/items/{id}", items),
+	)
+	handleTestEndpoints(t, srv, &EchoHandler{}, []EndpointMetadata{
+		{Name: "Echo.Get", Method: http.MethodGet, Path: "/v1/{name}"},
+	})
+	c := startTestServer(t, srv)
+
+	do := func(method, path string) (*http.Response, string) {
+		return c.do(c.request(method, path, nil))
+	}
+
+	rsp, body := do(http.MethodHead, "/items")
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.Empty(t, body)
+	require.Equal(t, int64(len("items")), rsp.ContentLength)
+
+	rsp, _ = do(http.MethodOptions, "/items")
+	require.Equal(t, http.StatusNoContent, rsp.StatusCode)
+	require.Equal(t, "GET, HEAD, OPTIONS, POST", rsp.Header.Get("Allow"))
+
+	rsp, _ = do(http.MethodPut, "/items")
+	require.Equal(t, http.StatusMethodNotAllowed, rsp.StatusCode)
+	require.Equal(t, "GET, HEAD, OPTIONS, POST", rsp.Header.Get("Allow"))
+
+	rsp, _ = do(http.MethodOptions, "/items/1")
+	require.Equal(t, "DELETE, OPTIONS", rsp.Header.Get("Allow"))
+
+	rsp, _ = do(http.MethodPost, "/v1/name")
+	require.Equal(t, http.StatusMethodNotAllowed, rsp.StatusCode)
+	require.Equal(t, "GET, HEAD, OPTIONS", rsp.Header.Get("Allow"))
+
+	rsp, _ = do(http.MethodOptions, "/unknown")
+	require.Equal(t, http.StatusNotFound, rsp.StatusCode)
+	require.Empty(t, rsp.Header.Get("Allow"))
+}
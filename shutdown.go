@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go.unistack.org/micro/v4/logger"
+	"go.unistack.org/micro/v4/options"
+	"go.unistack.org/micro/v4/server"
+)
+
+// ShutdownPhase is a step of graceful shutdown
+type ShutdownPhase string
+
+const (
+	// ShutdownPhaseNotReady marks server not ready, so balancers stop sending new requests
+	ShutdownPhaseNotReady ShutdownPhase = "not_ready"
+	// ShutdownPhasePreStop waits PreStopDelay while balancers observe readiness change
+	ShutdownPhasePreStop ShutdownPhase = "pre_stop"
+	// ShutdownPhaseDeregister removes server from register and disconnects broker
+	ShutdownPhaseDeregister ShutdownPhase = "deregister"
+	// ShutdownPhaseStopAccepting closes listeners
+	ShutdownPhaseStopAccepting ShutdownPhase = "stop_accepting"
+	// ShutdownPhaseDrain waits in-flight requests within GracefulTimeout
+	ShutdownPhaseDrain ShutdownPhase = "drain"
+	// ShutdownPhaseForceClose closes connections not drained within GracefulTimeout
+	ShutdownPhaseForceClose ShutdownPhase = "force_close"
+)
+
+// ServerShutdownPhaseDurationSeconds specifies meter metric name for shutdown phase duration
+var ServerShutdownPhaseDurationSeconds = "micro_server_shutdown_phase_duration_seconds"
+
+// ShutdownHook called on start of each shutdown phase, passed via server.Hooks
+type ShutdownHook func(ctx context.Context, phase ShutdownPhase)
+
+// shutdown reports shutdown phases to logger, meter and hooks
+type shutdown struct {
+	ts     time.Time
+	phase  ShutdownPhase
+	config server.Options
+}
+
+func (s *shutdown) enter(phase ShutdownPhase) {
+	s.finish()
+
+	s.phase = phase
+	s.ts = time.Now()
+
+	if s.config.Logger.V(logger.InfoLevel) {
+		s.config.Logger.Info(s.config.Context, fmt.Sprintf("Server %s-%s shutdown phase %s", s.config.Name, s.config.ID, phase))
+	}
+
+	s.config.Hooks.EachNext(func(hook options.Hook) {
+		if fn, ok := hook.(ShutdownHook); ok {
+			fn(s.config.Context, phase)
+		}
+	})
+}
+
+func (s *shutdown) finish() {
+	if s.phase == "" {
+		return
+	}
+	s.config.Meter.Histogram(ServerShutdownPhaseDurationSeconds, "server", "http", "phase", string(s.phase)).Update(time.Since(s.ts).Seconds())
+	s.phase = ""
+}
+
+// onceCloseListener allows to close listener before http.Server.Shutdown, that closes it again
+type onceCloseListener struct {
+	net.Listener
+	err  error
+	once sync.Once
+}
+
+func (l *onceCloseListener) Close() error {
+	l.once.Do(func() {
+		l.err = l.Listener.Close()
+	})
+	return l.err
+}
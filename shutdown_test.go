@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.unistack.org/micro/v4/server"
+)
+
+func TestServerGracefulShutdown(t *testing.T) {
+	var mu sync.Mutex
+	var phases []ShutdownPhase
+
+	started := make(chan struct{})
+	srv := NewServer(
+		server.Address("127.0.0.1:0"),
+		server.GracefulTimeout(2*time.Second),
+		PreStopDelay(200*time.Millisecond),
+		server.Hooks(ShutdownHook(func(_ context.Context, phase ShutdownPhase) {
+			mu.Lock()
+			phases = append(phases, phase)
+			mu.Unlock()
+		})),
+		PathHandler(http.MethodGet, "/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(300 * time.Millisecond)
+			_, _ = w.Write([]byte("slow"))
+		}),
+		PathHandler(http.MethodGet, "/fast", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("fast"))
+		}),
+	)
+	require.NoError(t, srv.Init())
+	require.NoError(t, srv.Start())
+	require.True(t, srv.Ready())
+
+	c := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func(path string) (string, error) {
+		rsp, err := c.Get("http://" + srv.Options().Address + path)
+		if err != nil {
+			return "", err
+		}
+		defer rsp.Body.Close()
+		buf, err := io.ReadAll(rsp.Body)
+		return string(buf), err
+	}
+
+	type result struct {
+		body string
+		err  error
+	}
+	slow := make(chan result, 1)
+	go func() {
+		body, err := get("/slow")
+		slow <- result{body: body, err: err}
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- srv.Stop()
+	}()
+
+	// not ready at once, but still serves requests during pre stop delay
+	require.Eventually(t, func() bool { return !srv.Ready() }, time.Second, time.Millisecond)
+	body, err := get("/fast")
+	require.NoError(t, err)
+	require.Equal(t, "fast", body)
+
+	// in-flight request drained
+	res := <-slow
+	require.NoError(t, res.err)
+	require.Equal(t, "slow", res.body)
+	require.NoError(t, <-stopped)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []ShutdownPhase{
+		ShutdownPhaseNotReady,
+		ShutdownPhasePreStop,
+		ShutdownPhaseDeregister,
+		ShutdownPhaseStopAccepting,
+		ShutdownPhaseDrain,
+	}, phases)
+
+	// listener closed
+	_, err = get("/fast")
+	require.Error(t, err)
+}
@@ -0,0 +1,267 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var (
+	errUnknownField   = errors.New("unknown field")
+	errDuplicateField = errors.New("duplicate field")
+	errTrailingData   = errors.New("trailing data after json value")
+)
+
+// DecodeError returned by strict decoding of request body
+type DecodeError struct {
+	Err error
+	// Path is json path of duplicate key like items[0].name, name of unknown field, empty for whole body
+	Path string
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v %s", e.Err, strconv.Quote(e.Path))
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// isJSONContentType reports whether content type is application/json or +json suffixed
+func isJSONContentType(ct string) bool {
+	mt, _, _ := strings.Cut(ct, ";")
+	mt = strings.ToLower(strings.TrimSpace(mt))
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// decodeStrictJSON decodes json body into request in strict mode, unknown fields, duplicate keys and
+// trailing data returned as *DecodeError. Proto messages decoded by protojson, which rejects them itself,
+// structs decoded by encoding/json with unknown fields disallowed.
+func decodeStrictJSON(buf []byte, v interface{}) error {
+	if len(bytes.TrimSpace(buf)) == 0 {
+		return nil
+	}
+
+	if pb, ok := v.(proto.Message); ok {
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: false}).Unmarshal(buf, pb); err != nil {
+			return strictError(err)
+		}
+		return nil
+	}
+
+	// encoding/json keeps last of duplicate keys silently, so they checked before decoding
+	if err := checkDuplicateKeys(buf, reflect.TypeOf(v)); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return strictError(err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return &DecodeError{Err: errTrailingData}
+	}
+
+	return nil
+}
+
+// strictError converts unknown and duplicate field errors of decoders to *DecodeError,
+// decoders report only field name, so it used as path
+func strictError(err error) error {
+	msg := err.Error()
+	for _, kind := range []error{errUnknownField, errDuplicateField} {
+		if _, name, ok := strings.Cut(msg, kind.Error()+" "); ok {
+			if uname, uerr := strconv.Unquote(name); uerr == nil {
+				name = uname
+			}
+			return &DecodeError{Err: kind, Path: name}
+		}
+	}
+	return err
+}
+
+// checkDuplicateKeys returns *DecodeError with json path of first key repeated in object,
+// struct fields resolved by name like encoding/json, so keys differ only in case are duplicates
+func checkDuplicateKeys(buf []byte, t reflect.Type) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	return walkJSON(dec, "", t)
+}
+
+var typeOfJSONUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// jsonElem returns type of values nested in json value of type t, nil if json value decoded by type itself
+func jsonElem(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil
+	}
+	if pt := reflect.PointerTo(t); pt.Implements(typeOfJSONUnmarshaler) || pt.Implements(typeOfTextUnmarshaler) {
+		return nil
+	}
+	return t
+}
+
+func walkJSON(dec *json.Decoder, path string, t reflect.Type) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	t = jsonElem(t)
+	switch tok {
+	case json.Delim('{'):
+		seen := make(map[string]struct{})
+		for dec.More() {
+			if tok, err = dec.Token(); err != nil {
+				return err
+			}
+			key, _ := tok.(string)
+			fpath := key
+			if path != "" {
+				fpath = path + "." + key
+			}
+			name := key
+			var ft reflect.Type
+			switch {
+			case t == nil:
+			case t.Kind() == reflect.Map:
+				ft = t.Elem()
+			case t.Kind() == reflect.Struct:
+				// unknown fields reported by decoder
+				if fn, f, ok := lookupJSONField(t, key); ok {
+					name, ft = fn, f.typ
+				}
+			}
+			if _, ok := seen[name]; ok {
+				return &DecodeError{Err: errDuplicateField, Path: fpath}
+			}
+			seen[name] = struct{}{}
+			if err = walkJSON(dec, fpath, ft); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		var et reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		}
+		for idx := 0; dec.More(); idx++ {
+			if err = walkJSON(dec, path+"["+strconv.Itoa(idx)+"]", et); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+
+	return err
+}
+
+// structJSONFields caches json field names by struct type
+var structJSONFields sync.Map
+
+// jsonField describes struct field encoded to json
+type jsonField struct {
+	typ   reflect.Type
+	index []int
+}
+
+// jsonFields returns json names of struct fields, embedded structs without tag promoted
+func jsonFields(t reflect.Type) map[string]jsonField {
+	if v, ok := structJSONFields.Load(t); ok {
+		return v.(map[string]jsonField)
+	}
+
+	fields := make(map[string]jsonField)
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fidx := append(index[:len(index):len(index)], i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, _, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					walk(ft, fidx)
+					continue
+				}
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if _, ok := fields[name]; !ok {
+				fields[name] = jsonField{typ: f.Type, index: fidx}
+			}
+		}
+	}
+	walk(t, nil)
+
+	v, _ := structJSONFields.LoadOrStore(t, fields)
+	return v.(map[string]jsonField)
+}
+
+// lookupJSONField returns struct field by json name, case insensitive like encoding/json
+func lookupJSONField(t reflect.Type, name string) (string, jsonField, bool) {
+	fields := jsonFields(t)
+	if f, ok := fields[name]; ok {
+		return name, f, true
+	}
+	for fn, f := range fields {
+		if strings.EqualFold(fn, name) {
+			return fn, f, true
+		}
+	}
+	return "", jsonField{}, false
+}
+
+// isWellKnownJSON reports whether message is well known type with special json form
+func isWellKnownJSON(md protoreflect.MessageDescriptor) bool {
+	switch md.FullName() {
+	case "google.protobuf.Any", "google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue",
+		"google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask",
+		"google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return true
+	}
+	return false
+}
+
+// strictDecoding reports whether handler request bodies decoded in strict mode,
+// handler option overrides server one
+func (h *Server) strictDecoding(handler *httpHandler) bool {
+	if handler != nil && handler.opts.Context != nil {
+		if v, ok := handler.opts.Context.Value(strictDecodingKey{}).(bool); ok {
+			return v
+		}
+	}
+	return h.strictDecode
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+type StrictTestItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type strictTestEmbedded struct {
+	Owner string `json:"owner"`
+}
+
+type StrictTestRequest struct {
+	strictTestEmbedded
+	Items  []*StrictTestItem          `json:"items"`
+	Labels map[string]*StrictTestItem `json:"labels"`
+	Extra  map[string]interface{}     `json:"extra"`
+	Title  string
+	Secret string `json:"-"`
+}
+
+func TestDecodeStrictJSON(t *testing.T) {
+	decode := func(buf string) error {
+		return decodeStrictJSON([]byte(buf), &StrictTestRequest{})
+	}
+
+	require.NoError(t, decodeStrictJSON(nil, &StrictTestRequest{}))
+	req := &StrictTestRequest{}
+	require.NoError(t, decodeStrictJSON([]byte(`{"owner":"a","items":[{"id":1},{"name":"b"}],`+
+		`"labels":{"x":{"id":2}},"extra":{"any":{"thing":[1]}},"title":"t"}`), req))
+	require.Equal(t, "a", req.Owner)
+	require.Equal(t, "b", req.Items[1].Name)
+	require.Equal(t, "t", req.Title)
+
+	// decoders report only name of unknown field, duplicate keys reported with json path
+	var derr *DecodeError
+	for buf, path := range map[string]string{
+		`{"items":[{"id":1},{"nmae":"b"}]}`:    "nmae",
+		`{"labels":{"x":{"bogus":1}}}`:         "bogus",
+		`{"Secret":"s"}`:                       "Secret",
+		`{"extra":{"a":1,"a":2}}`:              "extra.a",
+		`{"items":[{"id":1},{"id":1,"ID":2}]}`: "items[1].ID",
+		`{"owner":"a"} {"owner":"b"}`:          "",
+	} {
+		err := decode(buf)
+		require.ErrorAs(t, err, &derr, buf)
+		require.Equal(t, path, derr.Path, buf)
+	}
+	// map keys differ in case are not duplicates
+	require.NoError(t, decode(`{"labels":{"x":{"id":1},"X":{"id":2}}}`))
+	require.True(t, errors.Is(decode(`{"title":"a","title":"b"}`), errDuplicateField))
+	require.True(t, errors.Is(decode(`{"title":"a","Title":"b"}`), errDuplicateField))
+	require.True(t, errors.Is(decode(`{"nmae":"a"}`), errUnknownField))
+	require.True(t, errors.Is(decode(`{} x`), errTrailingData))
+
+	// proto messages decoded by protojson, it accepts json and proto names
+	pt := &typepb.Type{}
+	require.NoError(t, decodeStrictJSON([]byte(`{"name":"a","fields":[{"json_name":"x"},{"jsonName":"y"}],`+
+		`"sourceContext":{"fileName":"f"}}`), pt))
+	require.Equal(t, "y", pt.Fields[1].JsonName)
+	err := decodeStrictJSON([]byte(`{"fields":[{"json_name":"x","jsonName":"y"}]}`), &typepb.Type{})
+	require.True(t, errors.Is(err, errDuplicateField))
+	require.ErrorContains(t, err, `"jsonName"`)
+	require.ErrorContains(t, decodeStrictJSON([]byte(`{"fields":[{"name":"x"},{"nmae":"y"}]}`), &typepb.Type{}),
+		`unknown field "nmae"`)
+	require.Error(t, decodeStrictJSON([]byte(`{"name":"a"} x`), &typepb.Type{}))
+}
+
+func TestServerStrictDecoding(t *testing.T) {
+	srv := newTestServer(t)
+	require.NoError(t, srv.Handle(srv.NewHandler(&EchoHandler{}, HandlerStrictDecoding(true), HandlerEndpoints([]EndpointMetadata{
+		{Name: "Echo.Update", Method: http.MethodPost, Path: "/v1/update", Body: "*"},
+	}))))
+	require.NoError(t, srv.Handle(srv.NewHandler(&BindTestHandler{}, HandlerEndpoints([]EndpointMetadata{
+		{Name: "BindTest.Get", Method: http.MethodPost, Path: "/v1/bind", Body: "*"},
+	}))))
+	c := startTestServer(t, srv)
+
+	post := func(path, body string) (int, string) {
+		req := c.request(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tenant", "acme")
+		rsp, rbody := c.do(req)
+		return rsp.StatusCode, rbody
+	}
+
+	code, body := post("/v1/update", `{"name":"a"}`)
+	require.Equal(t, http.StatusOK, code, body)
+	require.JSONEq(t, `{"name":"a"}`, body)
+
+	code, body = post("/v1/update", `{"name":"a","nmae":"b"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, `unknown field "nmae"`, body)
+
+	// strict decoding not enabled for handler
+	code, body = post("/v1/bind", `{"name":"a","nmae":"b"}`)
+	require.Equal(t, http.StatusOK, code, body)
+}
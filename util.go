@@ -3,12 +3,14 @@ package http
 import (
 	"context"
 	"net/http"
-	"strings"
 
 	"go.unistack.org/micro/v4/metadata"
 	rutil "go.unistack.org/micro/v4/util/reflect"
 )
 
+// FillRequest sets request fields named like headers and cookies passed via Header and Cookie options
+// from incoming metadata, struct tags like header:"X-Tenant" and cookie:"session" bound automatically
+// by server, so it needed only for compatibility
 func FillRequest(ctx context.Context, req interface{}, opts ...FillRequestOption) error {
 	var err error
 	options := handlerOptions{}
@@ -21,7 +23,8 @@ func FillRequest(ctx context.Context, req interface{}, opts ...FillRequestOption
 		return nil
 	}
 
-	for idx := 0; idx < len(options.headers)/2; idx += 2 {
+	// options contain name and required flag pairs
+	for idx := 0; idx < len(options.headers); idx += 2 {
 		k := options.headers[idx]
 		v := md.Get(k)
 		if v == nil {
@@ -40,19 +43,17 @@ func FillRequest(ctx context.Context, req interface{}, opts ...FillRequestOption
 
 	cookieVals := md.Get("Cookie")
 	for i := range cookieVals {
-		cookies := strings.Split(cookieVals[i], ";")
+		// malformed cookies skipped
+		hr := http.Request{Header: http.Header{"Cookie": {cookieVals[i]}}}
+		cookies := hr.Cookies()
 		cmd := make(map[string]string, len(cookies))
 		for _, cookie := range cookies {
-			kv := strings.Split(cookie, "=")
-			if len(kv) != 2 {
-				continue
-			}
-			cmd[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+			cmd[cookie.Name] = cookie.Value
 		}
-		for idx := 0; idx < len(options.cookies)/2; idx += 2 {
+		for idx := 0; idx < len(options.cookies); idx += 2 {
 			k := http.CanonicalHeaderKey(options.cookies[idx])
-			v, ok := cmd[k]
-
+			// cookie names are case sensitive
+			v, ok := cmd[options.cookies[idx]]
 			if !ok {
 				continue
 			}
@@ -98,3 +98,30 @@ func TestFillrequest(t *testing.T) {
 		t.Fatalf("FillRequest error: %#+v", req)
 	}
 }
+
+func TestFillRequestPairs(t *testing.T) {
+	type request struct {
+		ClientID string
+		TraceID  string
+		Token    string
+		Session  string
+	}
+
+	md := metadata.New(3)
+	md.Set("ClientID", "xxx", "TraceID", "yyy", "Cookie", "Token=zzz; Session=a=b; bad")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	req := &request{}
+	if err := FillRequest(ctx, req,
+		Header("ClientID", "true", "TraceID", "true"),
+		Cookie("Token", "true", "Session", "true"),
+	); err != nil {
+		t.Fatal(err)
+	}
+	if req.ClientID != "xxx" || req.TraceID != "yyy" {
+		t.Fatalf("FillRequest headers error: %#+v", req)
+	}
+	if req.Token != "zzz" || req.Session != "a=b" {
+		t.Fatalf("FillRequest cookies error: %#+v", req)
+	}
+}